@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// altitudeReference is the zero reference for AltitudeRelative
+type altitudeReference struct {
+	m     *sync.Mutex
+	set   bool
+	value float64
+}
+
+// altitudeZero is captured by POST /zero-altitude, AltitudeRelative is omitted before
+var altitudeZero = altitudeReference{
+	m: &sync.Mutex{},
+}
+
+// relative returns the altitude 'alt' relative to the reference or nil if there is no reference
+func (a *altitudeReference) relative(alt float64) *float64 {
+	a.m.Lock()
+	defer a.m.Unlock()
+	if !a.set {
+		return nil
+	}
+	rel := alt - a.value
+	return &rel
+}
+
+// HTTP Handler to capture the current altitude as zero reference for AltitudeRelative
+func zeroAltitudeHandler(w http.ResponseWriter, r *http.Request) {
+	d.m.Lock()
+	if !d.fix {
+		d.m.Unlock()
+		httpError(w, "no GPS fix", http.StatusServiceUnavailable)
+		return
+	}
+	if d.Altitude == nil {
+		d.m.Unlock()
+		httpError(w, "no valid altitude", http.StatusServiceUnavailable)
+		return
+	}
+	altitudeZero.m.Lock()
+	altitudeZero.set = true
+	altitudeZero.value = *d.Altitude
+	altitudeZero.m.Unlock()
+	zero := 0.0
+	d.AltitudeRelative = &zero
+	d.stored = time.Now()
+	js, err := json.Marshal(struct{ AltitudeZero float64 }{*d.Altitude})
+	d.m.Unlock()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
+
+// plausibleAltitude returns false if the GGA altitude 'alt' is a placeholder of a receiver without
+// vertical solution, i.e. the field 'raw' is empty, 'alt' is one of --altitude-sentinel or it is
+// outside of --min-altitude and --max-altitude
+func plausibleAltitude(raw string, alt float64) bool {
+	if raw == "" || slices.Contains(*altitudeSentinels, alt) {
+		return false
+	}
+	return alt >= *minAltitude && alt <= *maxAltitude
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// meters returns a pointer to the altitude 'v'
+func meters(v float64) *float64 {
+	return &v
+}
+
+func TestPlausibleAltitude(t *testing.T) {
+	old := *altitudeSentinels
+	*altitudeSentinels = []float64{-9999, 0}
+	defer func() { *altitudeSentinels = old }()
+	tests := []struct {
+		raw  string
+		alt  float64
+		want bool
+	}{
+		{"545.4", 545.4, true},
+		{"-9999", -9999, false},
+		{"0.0", 0, false},
+		{"", 0, false},
+		{"-1000.1", -1000.1, false},
+		{"50000.1", 50000.1, false},
+	}
+	for _, tt := range tests {
+		if got := plausibleAltitude(tt.raw, tt.alt); got != tt.want {
+			t.Errorf("plausibleAltitude(%q) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestSentinelAltitudeOmitted(t *testing.T) {
+	u := newUpdater()
+	err := u.process(sentence("GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.p.Altitude == nil || *u.p.Altitude != 545.4 || !u.p.AltitudeValid {
+		t.Fatalf("altitude %v, valid %v, want 545.4", u.p.Altitude, u.p.AltitudeValid)
+	}
+
+	// The sentinel replaces the previous altitude instead of holding it
+	err = u.process(sentence("GPGGA,123520,4807.038,N,01131.000,E,1,08,0.9,-9999.0,M,46.9,M,,"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.p.Altitude != nil || u.p.AltitudeValid {
+		t.Fatalf("altitude %v, valid %v after the sentinel, want none", u.p.Altitude, u.p.AltitudeValid)
+	}
+	js, err := json.Marshal(u.p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f map[string]interface{}
+	err = json.Unmarshal(js, &f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alt, ok := f["Altitude"]; ok {
+		t.Errorf("JSON contains the altitude %v", alt)
+	}
+	if gga := buildGGA(u.p); !strings.Contains(gga, ",0.9,,M,") {
+		t.Errorf("GGA %v has an altitude", gga)
+	}
+}
+
+func TestSentinelAltitudeRecorders(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 10, 13, 12, 0, 0, 0, time.UTC)
+	fixes := []data{gpxPoint(start), gpxPoint(start.Add(time.Second)), gpxPoint(start.Add(2 * time.Second))}
+	fixes[1].Altitude = meters(300)
+
+	path := filepath.Join(t.TempDir(), "track.gpx")
+	g, err := openGPX(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk := track{m: &sync.Mutex{}}
+	tp := trip{m: &sync.Mutex{}}
+	for _, p := range fixes {
+		for _, s := range []Sink{g, &tk, &tp} {
+			err = s.Publish(ctx, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	g.close()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "<ele>"); n != 1 {
+		t.Errorf("GPX has %v elevations, want only the plausible one:\n%s", n, b)
+	}
+	if tk.Points[0].Altitude != nil || *tk.Points[1].Altitude != 300 {
+		t.Errorf("track altitudes %v and %v, want none and 300", tk.Points[0].Altitude, tk.Points[1].Altitude)
+	}
+	// The altitude is not interpolated towards a missing one
+	if p, ok := tk.at(start.Add(1500 * time.Millisecond)); !ok || p.Altitude != nil {
+		t.Errorf("interpolated altitude %v, want none", p.Altitude)
+	}
+	if s := tp.summary(); s.MaxAltitude == nil || *s.MaxAltitude != 300 {
+		t.Errorf("maximum altitude of the trip %v, want 300", s.MaxAltitude)
+	}
+}
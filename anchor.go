@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// anchorWatch raises an alarm when the position drifts beyond the radius around the anchor point,
+// e.g. for a boat at anchor. The excursion needs to persist for --anchor-debounce, so GPS noise at
+// the edge of the radius does not trigger false alarms.
+type anchorWatch struct {
+	m        *sync.Mutex
+	fix      bool    // true if the last position had a fix
+	lat, lon float64 // last position, not fuzzed
+	set      bool
+	anchor   struct{ lat, lon float64 }
+	radius   float64 // in meters
+	setAt    time.Time
+	distance float64 // current distance from the anchor in meters
+	maxDrift float64 // maximum distance from the anchor in meters since it was set
+	alarm    bool
+	since    time.Time // first time the opposite state was observed, zero if none
+}
+
+// anchor is the anchor watch of /anchor
+var anchor = anchorWatch{
+	m: &sync.Mutex{},
+}
+
+// anchorState is the JSON of /anchor
+type anchorState struct {
+	Set       bool
+	Latitude  float64   `json:",omitempty"`
+	Longitude float64   `json:",omitempty"`
+	Radius    float64   `json:",omitempty"`
+	SetAt     time.Time `json:",omitzero"`
+	Distance  float64
+	MaxDrift  float64
+	Alarm     bool
+}
+
+// update updates the watch with the position at 'now'. Without a fix the alarm state is kept.
+func (a *anchorWatch) update(now time.Time, fix bool, lat, lon float64) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	a.fix, a.lat, a.lon = fix, lat, lon
+	if !a.set || !fix {
+		return
+	}
+
+	a.distance = distance(a.anchor.lat, a.anchor.lon, lat, lon)
+	a.maxDrift = max(a.maxDrift, a.distance)
+	outside := a.distance > a.radius
+	if outside == a.alarm {
+		a.since = time.Time{}
+		return
+	}
+	if a.since.IsZero() {
+		a.since = now
+	}
+	if now.Sub(a.since) < *anchorDebounce {
+		return
+	}
+
+	a.alarm = outside
+	a.since = time.Time{}
+	if a.alarm {
+		event(eventAnchor, "dragging, %.1fm from the anchor with a radius of %vm", a.distance, a.radius)
+	} else {
+		event(eventAnchor, "back within %vm of the anchor", a.radius)
+	}
+}
+
+// drop sets the anchor to the current position with 'radius' in meters, it fails without a fix
+func (a *anchorWatch) drop(now time.Time, radius float64) error {
+	a.m.Lock()
+	defer a.m.Unlock()
+	if !a.fix {
+		return fmt.Errorf("no GPS fix")
+	}
+	a.set, a.radius, a.setAt = true, radius, now
+	a.anchor.lat, a.anchor.lon = a.lat, a.lon
+	a.distance, a.maxDrift, a.alarm, a.since = 0, 0, false, time.Time{}
+	event(eventAnchor, "set with a radius of %vm", radius)
+	return nil
+}
+
+// weigh clears the anchor and its alarm
+func (a *anchorWatch) weigh() {
+	a.m.Lock()
+	defer a.m.Unlock()
+	if a.set {
+		event(eventAnchor, "cleared")
+	}
+	a.set, a.alarm, a.since = false, false, time.Time{}
+}
+
+// state returns the JSON of the watch. The anchor point is fuzzed like the served positions.
+func (a *anchorWatch) state() anchorState {
+	a.m.Lock()
+	defer a.m.Unlock()
+	if !a.set {
+		return anchorState{}
+	}
+	p := fuzz(data{Latitude: a.anchor.lat, Longitude: a.anchor.lon})
+	return anchorState{
+		Set:       true,
+		Latitude:  p.Latitude,
+		Longitude: p.Longitude,
+		Radius:    a.radius,
+		SetAt:     a.setAt,
+		Distance:  a.distance,
+		MaxDrift:  a.maxDrift,
+		Alarm:     a.alarm,
+	}
+}
+
+// HTTP Handler of the anchor watch. GET reports the state, POST sets the anchor to the current
+// position with an optional ?radius=meters and DELETE clears it.
+func anchorHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		radius := *anchorRadius
+		if v := r.URL.Query().Get("radius"); v != "" {
+			var err error
+			radius, err = strconv.ParseFloat(v, 64)
+			if err != nil || radius <= 0 {
+				httpError(w, fmt.Sprintf("invalid radius %v, must be a positive number of meters", v), http.StatusBadRequest)
+				return
+			}
+		}
+		err := anchor.drop(time.Now(), radius)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	case http.MethodDelete:
+		anchor.weigh()
+	}
+
+	js, err := json.Marshal(anchor.state())
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
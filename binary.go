@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"log"
+	"math"
+	"net"
+	"sync"
+)
+
+// Layout of the binary records of --binary-listen. All fields are big endian.
+const (
+	recordSize    = 34     // size of a record in bytes
+	recordMagic   = 0x4e4d // "NM", marks the start of a record
+	recordVersion = 1      // version of the record layout
+)
+
+// Flags of the binary records
+const (
+	recordFix           = 1 << iota // GGA reports a fix
+	recordValid                     // last RMC had status A
+	recordMoving                    // Moving
+	recordDifferential              // Differential
+	recordCourseValid               // CourseValid
+	recordAltitudeValid             // AltitudeValid, the altitude is 0 otherwise
+)
+
+// records is the hub for all subscribers of --binary-listen
+var records = hub{
+	m:      &sync.Mutex{},
+	subs:   map[chan []byte]bool{},
+	encode: encodeRecord,
+}
+
+// encodeRecord encodes 'p' as binary record:
+//
+//	offset  size  field
+//	0       2     magic 0x4e4d ("NM")
+//	2       1     version, 1
+//	3       1     flags, bit 0 fix, 1 valid, 2 moving, 3 differential, 4 course valid,
+//	              5 altitude valid
+//	4       8     timestamp in milliseconds since the Unix epoch, int64
+//	12      4     latitude in 1e-7 degrees, int32
+//	16      4     longitude in 1e-7 degrees, int32
+//	20      4     altitude in centimeters, int32
+//	24      2     speed in 0.01 m/s, uint16
+//	26      2     course in 0.01 degrees, uint16
+//	28      1     number of satellites, uint8
+//	29      1     HDOP in 0.1, uint8, 255 if larger
+//	30      4     CRC-32 (IEEE) of the bytes 0 to 29, uint32
+func encodeRecord(p data) ([]byte, error) {
+	var flags byte
+	for flag, set := range map[byte]bool{
+		recordFix:           p.fix,
+		recordValid:         p.Valid,
+		recordMoving:        p.Moving,
+		recordDifferential:  p.Differential,
+		recordCourseValid:   p.CourseValid,
+		recordAltitudeValid: p.Altitude != nil,
+	} {
+		if set {
+			flags |= flag
+		}
+	}
+
+	b := make([]byte, recordSize)
+	binary.BigEndian.PutUint16(b[0:], recordMagic)
+	b[2] = recordVersion
+	b[3] = flags
+	binary.BigEndian.PutUint64(b[4:], uint64(p.Timestamp.UnixMilli()))
+	binary.BigEndian.PutUint32(b[12:], uint32(int32(math.Round(p.Latitude*1e7))))
+	binary.BigEndian.PutUint32(b[16:], uint32(int32(math.Round(p.Longitude*1e7))))
+	if p.Altitude != nil {
+		binary.BigEndian.PutUint32(b[20:], uint32(int32(math.Round(*p.Altitude*100))))
+	}
+	binary.BigEndian.PutUint16(b[24:], uint16(min(math.Round(p.Speed/3.6*100), math.MaxUint16)))
+	binary.BigEndian.PutUint16(b[26:], uint16(math.Round(mod360(p.Course)*100)))
+	b[28] = uint8(min(p.Satellites, math.MaxUint8))
+	b[29] = uint8(min(math.Round(p.HDOP*10), math.MaxUint8))
+	binary.BigEndian.PutUint32(b[30:], crc32.ChecksumIEEE(b[:30]))
+	return b, nil
+}
+
+// serveRecords accepts connections on --binary-listen and sends a binary record to each client
+// whenever 'd' is stored. It only returns if the listener fails.
+func serveRecords(l net.Listener) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go sendRecords(c)
+	}
+}
+
+// sendRecords sends the binary records to 'c' until it is closed
+func sendRecords(c net.Conn) {
+	defer c.Close()
+	updates, err := records.subscribe()
+	if err != nil {
+		log.Printf("Rejecting binary client %v, %v", c.RemoteAddr(), err)
+		return
+	}
+	defer records.unsubscribe(updates)
+	if *verbose {
+		log.Printf("Binary client %v connected\n", c.RemoteAddr())
+	}
+
+	// Clients don't send anything, the read only ends once the client disconnected. Without it a
+	// client that is gone keeps its subscriber slot until the next update fails.
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, c)
+		close(closed)
+	}()
+	for {
+		select {
+		case b, ok := <-updates:
+			if !ok {
+				return
+			}
+			_, err := c.Write(b)
+			if err != nil {
+				if *verbose {
+					log.Printf("Binary client %v disconnected, %v\n", c.RemoteAddr(), err)
+				}
+				return
+			}
+		case <-closed:
+			if *verbose {
+				log.Printf("Binary client %v disconnected\n", c.RemoteAddr())
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBinaryClientDisconnected(t *testing.T) {
+	server, client := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		sendRecords(server)
+		close(done)
+	}()
+	for end := time.Now().Add(time.Second); subscribers.Load() != 1; time.Sleep(time.Millisecond) {
+		if time.Now().After(end) {
+			t.Fatalf("%v subscribers, want the binary client", subscribers.Load())
+		}
+	}
+
+	// The slot is freed without waiting for an update
+	client.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sendRecords still running after the client disconnected")
+	}
+	if got := subscribers.Load(); got != 0 {
+		t.Errorf("%v subscribers after the client disconnected, want 0", got)
+	}
+}
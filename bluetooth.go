@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// bluetoothMaxDelay is the maximum delay between reconnects of --source bluetooth. RFCOMM links
+// take a few seconds to come back, retrying faster only keeps the Bluetooth stack busy.
+const bluetoothMaxDelay = 30 * time.Second
+
+// errHangup is returned when the RFCOMM link of --source bluetooth dropped
+var errHangup = errors.New("bluetooth link dropped")
+
+// rfcommPort is the serial port of an RFCOMM device. The serial library reports both a read
+// timeout and a dropped link as io.EOF, a dropped link returns it immediately though.
+type rfcommPort struct {
+	io.ReadCloser
+}
+
+// Read reads from the port and returns errHangup if the link dropped
+func (p rfcommPort) Read(b []byte) (int, error) {
+	start := time.Now()
+	n, err := p.ReadCloser.Read(b)
+	switch {
+	case err == io.EOF && n == 0 && time.Since(start) < *readTimeout/2:
+		return 0, errHangup
+	case err != nil && err != io.EOF:
+		return n, fmt.Errorf("%w, %v", errHangup, err)
+	}
+	return n, err
+}
+
+// openBluetooth opens the RFCOMM device given by --tty, e.g. /dev/rfcomm0. The serial format and
+// flow control are irrelevant for RFCOMM and not set.
+func openBluetooth() (input, error) {
+	s, err := serial.OpenPort(&serial.Config{Name: *tty, Baud: *baudrate, ReadTimeout: *readTimeout})
+	if err != nil {
+		return input{}, diagnoseTTY(*tty, err)
+	}
+	err = sendInitCommands(s)
+	if err != nil {
+		s.Close()
+		return input{}, err
+	}
+	openSerialErrors(*tty)
+	return input{ReadCloser: rfcommPort{ReadCloser: s}, w: s, retryEOF: true, reopen: true, backoff: true}, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// bounds is the bounding box of the track. West may be larger than East if the box crosses the
+// antimeridian.
+type bounds struct {
+	South  float64
+	West   float64
+	North  float64
+	East   float64
+	Center struct {
+		Latitude  float64
+		Longitude float64
+	}
+}
+
+// extend extends the bounding box by the position 'lat', 'lon'. 'empty' is true for the first position.
+func (b *bounds) extend(lat, lon float64, empty bool) {
+	if empty {
+		b.South, b.North, b.West, b.East = lat, lat, lon, lon
+	} else {
+		b.South = min(b.South, lat)
+		b.North = max(b.North, lat)
+		b.West, b.East = extendLongitudes(b.West, b.East, lon)
+	}
+	b.Center.Latitude = (b.South + b.North) / 2
+	b.Center.Longitude = normalizeLongitude(b.West + mod360(b.East-b.West)/2)
+}
+
+// HTTP Handler to send the bounding box of all track points of this session as JSON
+func boundsHandler(w http.ResponseWriter, r *http.Request) {
+	tr.m.Lock()
+	b, points := tr.bounds, tr.recorded
+	tr.m.Unlock()
+	if points == 0 {
+		httpError(w, "no track points", http.StatusServiceUnavailable)
+		return
+	}
+
+	js, err := json.Marshal(b)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
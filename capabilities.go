@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+)
+
+// endpoints are the registered HTTP routes, for /capabilities
+var endpoints []string
+
+// route registers the HTTP Handler 'h' for 'pattern' and records it as endpoint
+func route(pattern string, h http.HandlerFunc) {
+	endpoints = append(endpoints, pattern)
+	http.HandleFunc(pattern, h)
+}
+
+// capabilities is the JSON of /capabilities, what this build and configuration offers
+type capabilities struct {
+	Endpoints         []string
+	CoordinateFormats []string
+	Units             []string
+	SpeedUnits        []string // units of /speed?unit=
+	Encodings         []string
+}
+
+// currentCapabilities derives the capabilities from the registered routes and the configuration
+func currentCapabilities() capabilities {
+	c := capabilities{
+		CoordinateFormats: []string{"decimal", "gps", "dms"},
+		Units:             []string{unitsMetric, unitsImperial, unitsNautical},
+		Encodings:         []string{"json", "text", "sse"},
+	}
+	for _, e := range endpoints {
+		c.Endpoints = append(c.Endpoints, *basePath+e)
+	}
+	slices.Sort(c.Endpoints)
+	if *utm {
+		c.CoordinateFormats = append(c.CoordinateFormats, "utm")
+	}
+	if *geohashPrecision > 0 {
+		c.CoordinateFormats = append(c.CoordinateFormats, "geohash")
+	}
+	for u := range speedUnits {
+		c.SpeedUnits = append(c.SpeedUnits, u)
+	}
+	slices.Sort(c.SpeedUnits)
+	optional := []struct {
+		enabled  bool
+		encoding string
+	}{
+		{*metrics, "prometheus"},
+		{outputTemplate != nil, "template"},
+		{*binaryListen != "", "binary"},
+		{*grpcListen != "", "grpc"},
+		{*nmeaOutput != "", "nmea"},
+		{*gpxOutPath != "", "gpx"},
+		{*sqlitePath != "", "sqlite"},
+	}
+	for _, o := range optional {
+		if o.enabled {
+			c.Encodings = append(c.Encodings, o.encoding)
+		}
+	}
+	return c
+}
+
+// HTTP Handler to send the capabilities as JSON
+func capabilitiesHandler(w http.ResponseWriter, r *http.Request) {
+	js, err := json.Marshal(currentCapabilities())
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"time"
+
+	nmea "github.com/adrianmo/go-nmea"
+)
+
+// climbRate derives the vertical speed from successive GGA altitudes. Altitude is noisier than the
+// position, so the rates are averaged like SpeedSmoothed over the last --speed-window readings.
+type climbRate struct {
+	average  movingAverage
+	valid    bool          // true if 'altitude' and 'time' are set
+	altitude float64       // last altitude in meters
+	time     time.Duration // GGA time of day of the last altitude
+}
+
+// add adds the altitude 'alt' at the GGA time of day 'tod' and returns the averaged vertical speed
+// in m/s. Altitudes of the same epoch are skipped.
+func (c *climbRate) add(tod time.Duration, alt float64) float64 {
+	if !c.valid {
+		c.valid, c.altitude, c.time = true, alt, tod
+		return 0
+	}
+	dt := tod - c.time
+	if dt < 0 {
+		// Midnight passed
+		dt += 24 * time.Hour
+	}
+	if dt <= 0 {
+		return c.average.mean()
+	}
+	rate := (alt - c.altitude) / dt.Seconds()
+	c.altitude, c.time = alt, tod
+	return c.average.add(rate)
+}
+
+// reset restarts the derivation, e.g. after the fix or the altitude was lost
+func (c *climbRate) reset() {
+	c.valid = false
+	c.average.reset()
+}
+
+// timeOfDay returns the time of day of the nmea time 't' including the milliseconds
+func timeOfDay(t nmea.Time) time.Duration {
+	return time.Duration(t.Hour)*time.Hour + time.Duration(t.Minute)*time.Minute +
+		time.Duration(t.Second)*time.Second + time.Duration(t.Millisecond)*time.Millisecond
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// withChecksum returns the NMEA command 'cmd' terminated by CR LF. The '*HH' checksum is appended
+// if 'cmd' starts with '$' or '!' and does not have one yet, e.g. for "$PMTK220,1000" or
+// "$PUBX,40,GSV,0,0,0,0".
+func withChecksum(cmd string) string {
+	cmd = strings.TrimRight(cmd, "\r\n")
+	if (strings.HasPrefix(cmd, "$") || strings.HasPrefix(cmd, "!")) && !strings.Contains(cmd, "*") {
+		cmd += "*" + checksum(cmd[1:])
+	}
+	return cmd + "\r\n"
+}
+
+// sendInitCommands writes the --init-command commands to the receiver 'w', on every connect.
+func sendInitCommands(w io.Writer) error {
+	for _, cmd := range *initCommands {
+		_, err := io.WriteString(w, withChecksum(cmd))
+		if err != nil {
+			return fmt.Errorf("can't send init command %v, %v", cmd, err)
+		}
+		if *verbose {
+			log.Printf("Sent init command %v\n", strings.TrimSpace(withChecksum(cmd)))
+		}
+	}
+	return nil
+}
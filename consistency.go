@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log"
+	"math"
+
+	nmea "github.com/adrianmo/go-nmea"
+)
+
+// epochPosition is the position of a sentence with the time of day of its epoch
+type epochPosition struct {
+	time      nmea.Time
+	latitude  float64
+	longitude float64
+}
+
+// checkConsistency compares the positions of RMC and GGA of the same epoch and sets
+// PositionConsistencyMeters to their distance. Receivers report the same position in both, so a
+// distance above --max-position-inconsistency is logged as warning. If one of them has no valid
+// position, PositionConsistencyMeters is nil. Otherwise it is kept from the last common epoch.
+func (u *updater) checkConsistency() {
+	if u.rmcPosition == nil || u.ggaPosition == nil {
+		u.p.PositionConsistencyMeters = nil
+		return
+	}
+	if u.rmcPosition.time != u.ggaPosition.time {
+		return
+	}
+	dist := math.Round(distance(u.rmcPosition.latitude, u.rmcPosition.longitude, u.ggaPosition.latitude, u.ggaPosition.longitude)*100) / 100
+	u.p.PositionConsistencyMeters = &dist
+	if dist > *maxPositionInconsistency {
+		log.Printf("Warning: RMC and GGA positions of %02d:%02d:%02d differ by %vm", u.ggaPosition.time.Hour, u.ggaPosition.time.Minute, u.ggaPosition.time.Second, dist)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"text/template"
+	"time"
+)
+
+// outputTemplate is the parsed --output-template, nil if unset
+var outputTemplate *template.Template
+
+// parseOutputTemplate parses --output-template. It is executed once against sampleData, as unknown
+// fields are only detected on execution.
+func parseOutputTemplate() error {
+	t, err := template.New("output-template").Option("missingkey=error").Parse(*outputTemplateText)
+	if err != nil {
+		return fmt.Errorf("invalid output template, %v", err)
+	}
+	err = t.Execute(io.Discard, sampleData())
+	if err != nil {
+		return fmt.Errorf("invalid output template, %v", err)
+	}
+	outputTemplate = t
+	return nil
+}
+
+// sampleData returns data with all optional fields present, so a template that uses fields omitted
+// at the moment, e.g. .UTM.Easting or .SI.Speed, passes the check
+func sampleData() data {
+	var p data
+	populate(reflect.ValueOf(&p).Elem())
+	p.Constellations = map[string]constellationInfo{}
+	for _, c := range constellations {
+		p.Constellations[c] = constellationInfo{}
+	}
+	return p
+}
+
+// populate allocates the nil pointers and slices of the exported fields of the struct 'v', slices
+// get a single element
+func populate(v reflect.Value) {
+	for i := range v.NumField() {
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.Pointer:
+			f.Set(reflect.New(f.Type().Elem()))
+			if f.Elem().Kind() == reflect.Struct {
+				populate(f.Elem())
+			}
+		case reflect.Slice:
+			f.Set(reflect.MakeSlice(f.Type(), 1, 1))
+			if f.Index(0).Kind() == reflect.Struct {
+				populate(f.Index(0))
+			}
+		case reflect.Struct:
+			populate(f)
+		}
+	}
+}
+
+// customHandler responds with the GPS data formatted with --output-template
+func customHandler(w http.ResponseWriter, r *http.Request) {
+	p := snapshot()
+	p.Age = time.Since(p.update)
+	var b bytes.Buffer
+	err := outputTemplate.Execute(&b, inUnits(p))
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write(b.Bytes())
+}
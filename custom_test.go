@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestParseOutputTemplate(t *testing.T) {
+	old, oldTemplate := *outputTemplateText, outputTemplate
+	defer func() { *outputTemplateText, outputTemplate = old, oldTemplate }()
+	tests := []struct {
+		text  string
+		valid bool
+	}{
+		{"{{.Latitude}};{{.Longitude}}", true},
+		// Fields within omitted ones are known
+		{"{{.UTM.Easting}};{{.SI.Speed}};{{.DGPSAge}}", true},
+		{"{{index .SatellitesUsed 0}};{{.Constellations.gps.SatellitesInView}}", true},
+		{"{{.Unknown}}", false},
+		{"{{.UTM.Unknown}}", false},
+		{"{{.Latitude", false},
+	}
+	for _, tt := range tests {
+		*outputTemplateText = tt.text
+		if err := parseOutputTemplate(); (err == nil) != tt.valid {
+			t.Errorf("parseOutputTemplate(%q) = %v, want valid %v", tt.text, err, tt.valid)
+		}
+	}
+}
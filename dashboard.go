@@ -0,0 +1,27 @@
+package main
+
+import (
+	"embed"
+	"net/http"
+	"strings"
+)
+
+// assets holds the files of the dashboard, embedded into the binary
+//
+//go:embed assets
+var assets embed.FS
+
+// HTTP Handler to send the dashboard
+func dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	http.ServeFileFS(w, r, assets, "assets/dashboard.html")
+}
+
+// HTTP Handler to send the favicon
+func faviconHandler(w http.ResponseWriter, r *http.Request) {
+	http.ServeFileFS(w, r, assets, "assets/favicon.ico")
+}
+
+// wantsHTML returns true if the client prefers HTML, e.g. a browser
+func wantsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
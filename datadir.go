@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// resolveDataDir makes the relative paths of all file outputs relative to --data-dir and checks
+// that it is writable, so a read-only mount fails at start and not when a feature first writes
+func resolveDataDir() error {
+	if *dataDir == "" {
+		return nil
+	}
+	f, err := os.CreateTemp(*dataDir, ".nmea-service-*")
+	if err != nil {
+		return fmt.Errorf("data directory %v is not writable, %v", *dataDir, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	for _, path := range []*string{recordFile, stateFile, sqlitePath, gpxOutPath, logFile} {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(*dataDir, *path)
+		}
+	}
+	return nil
+}
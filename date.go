@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/adrianmo/go-nmea"
+)
+
+// fullYear returns the four digit year of the two digit RMC year 'yy'. Years below --year-pivot
+// are in this century, all others in the last, e.g. with the default pivot of 80 the year 79 is
+// 2079 and 80 is 1980, the start of the GPS time.
+func fullYear(yy int) int {
+	if yy < *yearPivot {
+		return 2000 + yy
+	}
+	return 1900 + yy
+}
+
+// rmcYear returns the four digit year of the two digit RMC year 'yy'. The year of the last ZDA
+// sentence is preferred as long as it matches, e.g. until it is outdated at the turn of the year.
+func (u *updater) rmcYear(yy int) int {
+	if u.zdaYear != 0 && u.zdaYear%100 == yy {
+		return u.zdaYear
+	}
+	return fullYear(yy)
+}
+
+// processZDA records the four digit year of a ZDA sentence of any talker, the nmea parser does
+// not support it. Its date and time also update the timestamp if it is newer.
+func (u *updater) processZDA(sentence string) error {
+	_, _, fields, err := splitSentence(sentence)
+	if err != nil {
+		return err
+	}
+	if len(fields) < 4 {
+		return fmt.Errorf("ZDA with %v fields", len(fields))
+	}
+
+	// Receivers without a time yet send empty fields
+	if fields[0] == "" || fields[3] == "" {
+		return nil
+	}
+	var date [3]int64
+	for i := range date {
+		date[i], err = parseInt(fields[i+1])
+		if err != nil {
+			return err
+		}
+	}
+	day, month, year := date[0], date[1], date[2]
+	if year < 1980 || year > 9999 {
+		return fmt.Errorf("invalid ZDA year %v", year)
+	}
+	tod, err := parseTimeOfDay(fields[0])
+	if err != nil {
+		return err
+	}
+	u.zdaYear = int(year)
+	u.setTimestamp(time.Date(int(year), time.Month(month), int(day), 0, 0, 0, 0, time.UTC).Add(tod))
+	if *verbose {
+		log.Printf("ZDA year: %v\n", u.zdaYear)
+	}
+	return nil
+}
+
+// parseTimeOfDay parses the NMEA time of day 'field' in the format hhmmss.ss including the
+// milliseconds
+func parseTimeOfDay(field string) (time.Duration, error) {
+	if len(field) < 6 {
+		return 0, fmt.Errorf("invalid time %v", field)
+	}
+	var hms [3]int64
+	for i := range hms {
+		v, err := parseInt(field[2*i : 2*i+2])
+		if err != nil {
+			return 0, err
+		}
+		hms[i] = v
+	}
+	ms, err := milliseconds(field)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(hms[0])*time.Hour + time.Duration(hms[1])*time.Minute + time.Duration(hms[2])*time.Second +
+		time.Duration(ms)*time.Millisecond, nil
+}
+
+// milliseconds returns the milliseconds of the fraction of the NMEA time 'field', e.g. 250 for
+// 123519.25. Digits beyond milliseconds are truncated.
+func milliseconds(field string) (int, error) {
+	_, fraction, ok := strings.Cut(field, ".")
+	if !ok || fraction == "" {
+		return 0, nil
+	}
+	ms, err := parseInt((fraction + "00")[:3])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %v", field)
+	}
+	return int(ms), nil
+}
+
+// withMilliseconds corrects the milliseconds of 't' parsed by the nmea parser from the time
+// 'field'. The parser takes the digits of the fraction as milliseconds, e.g. 5 for 123519.5.
+func withMilliseconds(t nmea.Time, field string) nmea.Time {
+	if ms, err := milliseconds(field); t.Valid && err == nil {
+		t.Millisecond = ms
+	}
+	return t
+}
+
+// onDateOf returns the time of day 'tod' on the date of the timestamp 'last'. GGA only has the time
+// of day, so after midnight it is on the next day until RMC or ZDA report the new date. Times more
+// than 12 hours before 'last' are after midnight, more than 12 hours after it before midnight.
+func onDateOf(last time.Time, tod time.Duration) time.Time {
+	t := time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, time.UTC).Add(tod)
+	switch {
+	case t.Before(last.Add(-12 * time.Hour)):
+		return t.Add(24 * time.Hour)
+	case t.After(last.Add(12 * time.Hour)):
+		return t.Add(-24 * time.Hour)
+	}
+	return t
+}
+
+// setTimestamp sets the timestamp to 't' if it is newer, so sentences of a cycle with the same
+// or an older time don't move it back
+func (u *updater) setTimestamp(t time.Time) {
+	if !t.After(u.p.Timestamp) {
+		return
+	}
+	u.p.Timestamp = t
+	u.p.TimestampLocal = t.In(location)
+	u.dirty = true
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFullYear(t *testing.T) {
+	tests := []struct {
+		pivot, yy, want int
+	}{
+		{80, 0, 2000},
+		{80, 26, 2026},
+		{80, 79, 2079},
+		{80, 80, 1980},
+		{80, 99, 1999},
+		// With a pivot of 100 all years are in this century, so 99 rolls over to 00 within it
+		{100, 99, 2099},
+		{100, 0, 2000},
+		{0, 0, 1900},
+	}
+	old := *yearPivot
+	defer func() { *yearPivot = old }()
+	for _, tt := range tests {
+		*yearPivot = tt.pivot
+		if got := fullYear(tt.yy); got != tt.want {
+			t.Errorf("fullYear(%v) with pivot %v = %v, want %v", tt.yy, tt.pivot, got, tt.want)
+		}
+	}
+}
+
+func TestRMCYearPrefersZDA(t *testing.T) {
+	tests := []struct {
+		zdaYear, yy, want int
+	}{
+		{0, 99, 1999},
+		{2099, 99, 2099},
+		{2100, 0, 2100},
+		// An outdated ZDA year is not used
+		{2026, 27, 2027},
+	}
+	for _, tt := range tests {
+		u := updater{zdaYear: tt.zdaYear}
+		if got := u.rmcYear(tt.yy); got != tt.want {
+			t.Errorf("rmcYear(%v) after ZDA year %v = %v, want %v", tt.yy, tt.zdaYear, got, tt.want)
+		}
+	}
+}
+
+func TestRMCRollover(t *testing.T) {
+	u := newUpdater()
+	for _, tt := range []struct {
+		rmc  string
+		want time.Time
+	}{
+		{"GPRMC,235959,A,4807.038,N,01131.000,E,0.0,0.0,311299,,,A", time.Date(1999, 12, 31, 23, 59, 59, 0, time.UTC)},
+		{"GPRMC,000000,A,4807.038,N,01131.000,E,0.0,0.0,010100,,,A", time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		err := u.process(sentence(tt.rmc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !u.p.Timestamp.Equal(tt.want) {
+			t.Errorf("timestamp of %v = %v, want %v", tt.rmc, u.p.Timestamp, tt.want)
+		}
+	}
+}
+
+func TestGGATimestamp(t *testing.T) {
+	tests := []struct {
+		name     string
+		sentence []string
+		want     time.Time
+	}{
+		{"milliseconds", []string{
+			"GPRMC,123519.00,A,4807.038,N,01131.000,E,0.0,0.0,141026,,,A",
+			"GPGGA,123519.25,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,",
+		}, time.Date(2026, 10, 14, 12, 35, 19, 250*int(time.Millisecond), time.UTC)},
+		{"after midnight", []string{
+			"GPRMC,235959.50,A,4807.038,N,01131.000,E,0.0,0.0,311226,,,A",
+			"GPGGA,000000.20,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,",
+		}, time.Date(2027, 1, 1, 0, 0, 0, 200*int(time.Millisecond), time.UTC)},
+		// A GGA of the previous day arriving after the RMC of the new one does not move back
+		{"before midnight", []string{
+			"GPRMC,000000.00,A,4807.038,N,01131.000,E,0.0,0.0,010127,,,A",
+			"GPGGA,235959.90,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,",
+		}, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		u := newUpdater()
+		for _, s := range tt.sentence {
+			err := u.process(sentence(s))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !u.p.Timestamp.Equal(tt.want) {
+			t.Errorf("%v: timestamp %v, want %v", tt.name, u.p.Timestamp, tt.want)
+		}
+	}
+}
+
+func TestParseTimeOfDay(t *testing.T) {
+	tests := []struct {
+		field string
+		want  time.Duration
+		ok    bool
+	}{
+		{"123519", 12*time.Hour + 35*time.Minute + 19*time.Second, true},
+		{"123519.", 12*time.Hour + 35*time.Minute + 19*time.Second, true},
+		{"123519.5", 12*time.Hour + 35*time.Minute + 19500*time.Millisecond, true},
+		{"123519.25", 12*time.Hour + 35*time.Minute + 19250*time.Millisecond, true},
+		{"123519.025", 12*time.Hour + 35*time.Minute + 19025*time.Millisecond, true},
+		{"000000.1234", 123 * time.Millisecond, true},
+		{"12351", 0, false},
+		{"123519.x", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeOfDay(tt.field)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseTimeOfDay(%v) = %v, %v, want %v", tt.field, got, err, tt.want)
+		}
+	}
+}
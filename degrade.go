@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Indices of the fields of GGA and RMC that degradation rewrites
+const (
+	ggaLatitudeField = 1
+	ggaQualityField  = 5
+	rmcStatusField   = 1
+)
+
+// degradation injects degraded conditions into the sentences of the source for testing clients,
+// see the --degrade-* flags. The rates are probabilities per sentence.
+type degradation struct {
+	fixLostUntil time.Time // end of the injected fix loss, zero if none
+}
+
+// degrader degrades the sentences in readLines, which only runs once at a time
+var degrader degradation
+
+// degrading returns true if any --degrade-* flag is set
+func degrading() bool {
+	return *degradeDrop > 0 || *degradeCorrupt > 0 || *degradeFixLoss > 0 || *degradeJump > 0
+}
+
+// apply degrades the sentence at 'now' and returns false if it is dropped. Fix losses and position
+// jumps are reported as event, so they are never mistaken for the real behavior of the receiver.
+func (g *degradation) apply(now time.Time, sentence string) (string, bool) {
+	if rand.Float64() < *degradeDrop {
+		degradedSentences.Add(1)
+		return "", false
+	}
+	degraded := sentence
+	switch sentenceType(sentence) {
+	case "GGA":
+		if g.startFixLoss(now) {
+			degraded = replaceField(degraded, ggaQualityField, fixInvalid)
+		}
+		if rand.Float64() < *degradeJump {
+			degraded = jumpPosition(degraded, ggaLatitudeField)
+		}
+	case "RMC":
+		if g.startFixLoss(now) {
+			degraded = replaceField(degraded, rmcStatusField, "V")
+		}
+	}
+	if rand.Float64() < *degradeCorrupt {
+		// Flip a bit of the checksum
+		i := strings.LastIndex(degraded, "*")
+		if i >= 0 && i+1 < len(degraded) {
+			degraded = degraded[:i+1] + string(degraded[i+1]^1) + degraded[i+2:]
+		}
+	}
+	if degraded != sentence {
+		degradedSentences.Add(1)
+	}
+	return degraded, true
+}
+
+// startFixLoss returns true while the fix is lost at 'now' and starts a new fix loss of
+// --degrade-fix-loss-duration at the rate --degrade-fix-loss
+func (g *degradation) startFixLoss(now time.Time) bool {
+	if now.Before(g.fixLostUntil) {
+		return true
+	}
+	if rand.Float64() >= *degradeFixLoss {
+		return false
+	}
+	g.fixLostUntil = now.Add(*degradeFixLossDuration)
+	event(eventDegradation, "injected fix loss for %v", *degradeFixLossDuration)
+	return true
+}
+
+// replaceField replaces the data field 'i' of the raw NMEA 'sentence' and updates the checksum.
+// Sentences that can't be split are returned unchanged.
+func replaceField(sentence string, i int, value string) string {
+	fields, err := sentenceFields(sentence)
+	if err != nil || i+1 >= len(fields) {
+		return sentence
+	}
+	fields[i+1] = value
+	return strings.TrimRight(withChecksum(sentence[:1]+strings.Join(fields, ",")), "\r\n")
+}
+
+// jumpPosition moves the position of the raw NMEA 'sentence', with the latitude in field 'i',
+// by --degrade-jump-distance meters in a random direction
+func jumpPosition(sentence string, i int) string {
+	fields, err := sentenceFields(sentence)
+	if err != nil || i+4 >= len(fields) {
+		return sentence
+	}
+	lat, err1 := parseNMEACoordinate(fields[i+1], fields[i+2], "S")
+	lon, err2 := parseNMEACoordinate(fields[i+3], fields[i+4], "W")
+	if err1 != nil || err2 != nil {
+		return sentence
+	}
+	lat, lon = destination(lat, lon, rand.Float64()*360, *degradeJumpDistance)
+	latitude, longitude := nmeaCoordinate(lat, 2, "N", "S"), nmeaCoordinate(lon, 3, "E", "W")
+	fields[i+1], fields[i+2], _ = strings.Cut(latitude, ",")
+	fields[i+3], fields[i+4], _ = strings.Cut(longitude, ",")
+	event(eventDegradation, "injected position jump of %vm", *degradeJumpDistance)
+	return strings.TrimRight(withChecksum(sentence[:1]+strings.Join(fields, ",")), "\r\n")
+}
+
+// parseNMEACoordinate parses an NMEA coordinate like ddmm.mmmm or dddmm.mmmm in decimal degrees,
+// negative in the hemisphere 'negative'
+func parseNMEACoordinate(value, hemisphere, negative string) (float64, error) {
+	i := strings.Index(value, ".")
+	if i < 0 {
+		i = len(value)
+	}
+	if i < 3 {
+		return 0, fmt.Errorf("invalid coordinate %v", value)
+	}
+	degrees, err := strconv.ParseFloat(value[:i-2], 64)
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.ParseFloat(value[i-2:], 64)
+	if err != nil {
+		return 0, err
+	}
+	v := degrees + minutes/60
+	if hemisphere == negative {
+		v = -v
+	}
+	return v, nil
+}
+
+// logDegradation warns that degradation is active, regardless of --verbose
+func logDegradation() {
+	log.Printf("Warning: degradation for testing is active, the GPS data is NOT real: dropping %v%%, "+
+		"corrupting %v%%, fix loss at %v%% for %v, position jumps at %v%% of %vm",
+		percent(*degradeDrop), percent(*degradeCorrupt), percent(*degradeFixLoss), *degradeFixLossDuration,
+		percent(*degradeJump), *degradeJumpDistance)
+}
+
+// percent converts the rate 'r' to percent for logging
+func percent(r float64) float64 {
+	return math.Round(r*10000) / 100
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// deltaSIFields are the converted fields of --units whose SI values are in SI. SI is only sent
+// along with them, so it follows their thresholds.
+var deltaSIFields = map[string]bool{"Speed": true, "SpeedSmoothed": true, "Altitude": true, "AltitudeRelative": true, "VerticalSpeed": true, "LatitudeError": true, "LongitudeError": true, "AltitudeError": true}
+
+// deltaIgnored are the fields that change with every update and never make up a delta on their own
+var deltaIgnored = map[string]bool{"Timestamp": true, "TimestampLocal": true, "Age": true, "Updated": true, "Full": true}
+
+// Fields of the delta feed that only change beyond a threshold, as they are noisy
+var (
+	deltaPositionFields = map[string]bool{"Latitude": true, "Longitude": true, "LatitudeGPS": true, "LongitudeGPS": true, "LatitudeDMS": true, "LongitudeDMS": true, "UTM": true, "Geohash": true}
+	deltaAltitudeFields = map[string]bool{"Altitude": true, "AltitudeRelative": true}
+	deltaSpeedFields    = map[string]bool{"Speed": true, "SpeedSmoothed": true}
+)
+
+// deltaEncoder encodes the GPS data as delta feed. An update only contains the fields that changed
+// since the last update, plus the timestamp. Every --delta-full-interval a full snapshot is sent.
+type deltaEncoder struct {
+	m        *sync.Mutex
+	last     map[string]interface{} // fields as last sent
+	position data                   // GPS data with the last sent position, altitude and speed
+	full     time.Time              // time of the last full snapshot
+}
+
+// deltaFeed is the encoder of the deltas
+var deltaFeed = &deltaEncoder{m: &sync.Mutex{}}
+
+// deltas is the hub for all subscribers of /stream?delta=true. A subscriber that skipped a delta
+// gets a full snapshot instead of the next one, as the skipped changes are missing in it.
+var deltas = hub{
+	m:      &sync.Mutex{},
+	subs:   map[chan []byte]bool{},
+	encode: deltaFeed.encode,
+	full:   encodeFull,
+	reset:  deltaFeed.reset,
+}
+
+// jsonFields returns the JSON fields of 'p'
+func jsonFields(p data) (map[string]interface{}, error) {
+	js, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	var f map[string]interface{}
+	err = json.Unmarshal(js, &f)
+	return f, err
+}
+
+// encodeFull returns all fields of 'p' as full snapshot of the delta feed
+func encodeFull(p data) ([]byte, error) {
+	p.Age = time.Since(p.update)
+	current, err := jsonFields(inUnits(p))
+	if err != nil {
+		return nil, err
+	}
+	current["Full"] = true
+	return json.Marshal(current)
+}
+
+// reset makes the next update a full snapshot, e.g. for a new subscriber
+func (e *deltaEncoder) reset() {
+	e.m.Lock()
+	defer e.m.Unlock()
+	e.last = nil
+}
+
+// encode returns the changed fields of 'p' as JSON, nil if nothing changed. The thresholds apply
+// to the internal units, regardless of --units.
+func (e *deltaEncoder) encode(p data) ([]byte, error) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	p.Age = time.Since(p.update)
+	current, err := jsonFields(inUnits(p))
+	if err != nil {
+		return nil, err
+	}
+
+	if e.last == nil || time.Since(e.full) >= *deltaFullInterval {
+		e.last, e.position, e.full = current, p, time.Now()
+		current["Full"] = true
+		return json.Marshal(current)
+	}
+
+	moved := distance(e.position.Latitude, e.position.Longitude, p.Latitude, p.Longitude) > *deltaDistance
+	// Gaining or losing the altitude is always sent
+	climbed := (p.Altitude == nil) != (e.position.Altitude == nil) ||
+		p.Altitude != nil && math.Abs(*p.Altitude-*e.position.Altitude) > *deltaAltitude
+	accelerated := math.Abs(p.Speed-e.position.Speed) > *deltaSpeed
+	changed := map[string]interface{}{}
+	for k, v := range current {
+		switch {
+		case deltaIgnored[k]:
+		case deltaPositionFields[k]:
+			if moved {
+				changed[k] = v
+			}
+		case deltaAltitudeFields[k]:
+			if climbed {
+				changed[k] = v
+			}
+		case deltaSpeedFields[k]:
+			if accelerated {
+				changed[k] = v
+			}
+		case k == "SI":
+		case !reflect.DeepEqual(v, e.last[k]):
+			changed[k] = v
+		}
+	}
+	// Omitted fields are sent as null
+	for k := range e.last {
+		if _, ok := current[k]; !ok && !deltaIgnored[k] {
+			changed[k] = nil
+		}
+	}
+	// The SI values of --units are sent along with the changed fields they belong to
+	if si, ok := current["SI"]; ok {
+		for k := range changed {
+			if deltaSIFields[k] {
+				changed["SI"] = si
+				break
+			}
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	for k, v := range changed {
+		e.last[k] = v
+		if v == nil {
+			delete(e.last, k)
+		}
+	}
+	if moved {
+		e.position.Latitude, e.position.Longitude = p.Latitude, p.Longitude
+	}
+	if climbed {
+		e.position.Altitude = p.Altitude
+	}
+	if accelerated {
+		e.position.Speed = p.Speed
+	}
+	changed["Timestamp"] = current["Timestamp"]
+	return json.Marshal(changed)
+}
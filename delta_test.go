@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+// receive returns the fields of the update pending for the subscriber 'c', nil if there is none
+func receive(t *testing.T, c chan []byte) map[string]interface{} {
+	t.Helper()
+	select {
+	case js := <-c:
+		var f map[string]interface{}
+		err := json.Unmarshal(js, &f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	default:
+		return nil
+	}
+}
+
+func TestDeltaSkippedUpdate(t *testing.T) {
+	h := hub{m: &sync.Mutex{}, subs: map[chan []byte]bool{}, encode: (&deltaEncoder{m: &sync.Mutex{}}).encode, full: encodeFull}
+	c, err := h.subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.unsubscribe(c)
+	ctx := context.Background()
+
+	h.Publish(ctx, data{Satellites: 5, HDOP: 1})
+	if f := receive(t, c); f["Full"] != true {
+		t.Fatalf("first update %v is no full snapshot", f)
+	}
+	h.Publish(ctx, data{Satellites: 6, HDOP: 1})
+	if f := receive(t, c); len(f) != 2 || f["Satellites"] != 6.0 || f["HDOP"] != nil {
+		t.Errorf("delta %v, want only Satellites 6 and the Timestamp", f)
+	}
+
+	// The subscriber does not receive the second delta in time, so it gets a full snapshot
+	h.Publish(ctx, data{Satellites: 7, HDOP: 1})
+	h.Publish(ctx, data{Satellites: 7, HDOP: 2})
+	f := receive(t, c)
+	if f["Full"] != true || f["Satellites"] != 7.0 || f["HDOP"] != 2.0 {
+		t.Errorf("update %v after a skipped delta, want a full snapshot with Satellites 7 and HDOP 2", f)
+	}
+	h.Publish(ctx, data{Satellites: 8, HDOP: 2})
+	if f := receive(t, c); f["Full"] != nil || f["Satellites"] != 8.0 {
+		t.Errorf("delta %v, want Satellites 8", f)
+	}
+}
+
+func TestDeltaSIThresholds(t *testing.T) {
+	old := *outputUnits
+	*outputUnits = unitsImperial
+	defer func() { *outputUnits = old }()
+	e := &deltaEncoder{m: &sync.Mutex{}}
+	tests := []struct {
+		p      data
+		fields []string // changed fields besides Timestamp, nil if nothing is sent
+	}{
+		{data{Speed: 10, Altitude: meters(500), Satellites: 5}, nil},
+		// The speed and altitude change within the thresholds
+		{data{Speed: 10.5, Altitude: meters(501), Satellites: 5}, nil},
+		{data{Speed: 10.5, Altitude: meters(501), Satellites: 6}, []string{"Satellites"}},
+		{data{Speed: 20, Altitude: meters(501), Satellites: 6}, []string{"Speed", "SpeedSmoothed", "SI"}},
+	}
+	for i, tt := range tests {
+		js, err := e.encode(tt.p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			continue
+		}
+		if tt.fields == nil {
+			if js != nil {
+				t.Errorf("%v: sent %s, want nothing", i, js)
+			}
+			continue
+		}
+		var f map[string]interface{}
+		err = json.Unmarshal(js, &f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		delete(f, "Timestamp")
+		if len(f) != len(tt.fields) {
+			t.Errorf("%v: sent %s, want %v", i, js, tt.fields)
+		}
+		for _, k := range tt.fields {
+			if _, ok := f[k]; !ok {
+				t.Errorf("%v: sent %s without %v", i, js, k)
+			}
+		}
+	}
+}
+
+func TestDeltaNewSubscriber(t *testing.T) {
+	e := &deltaEncoder{m: &sync.Mutex{}}
+	h := hub{m: &sync.Mutex{}, subs: map[chan []byte]bool{}, encode: e.encode, full: encodeFull, reset: e.reset}
+	ctx := context.Background()
+	first, err := h.subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.unsubscribe(first)
+	h.Publish(ctx, data{Satellites: 5})
+	h.Publish(ctx, data{Satellites: 6})
+	receive(t, first)
+
+	// The new subscriber starts with the last published data
+	c, err := h.subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.unsubscribe(c)
+	if f := receive(t, c); f["Full"] != true || f["Satellites"] != 6.0 {
+		t.Fatalf("first update %v, want a full snapshot with Satellites 6", f)
+	}
+	// The revert reaches both subscribers
+	h.Publish(ctx, data{Satellites: 5})
+	for i, s := range []chan []byte{first, c} {
+		if f := receive(t, s); f["Satellites"] != 5.0 {
+			t.Errorf("subscriber %v received %v after the revert, want Satellites 5", i, f)
+		}
+	}
+	h.Publish(ctx, data{Satellites: 6})
+	if f := receive(t, c); f["Full"] != nil || f["Satellites"] != 6.0 {
+		t.Errorf("delta %v, want Satellites 6", f)
+	}
+}
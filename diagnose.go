@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// serialDevicePatterns are the usual serial devices of GPS receivers for the hint on a missing device
+var serialDevicePatterns = []string{"/dev/ttyUSB*", "/dev/ttyACM*", "/dev/ttyAMA*", "/dev/ttyS*", "/dev/rfcomm*", "/dev/serial/by-id/*"}
+
+// diagnoseTTY explains the error 'err' of opening the serial device 'name', as the errors of the
+// serial library are often cryptic for new users
+func diagnoseTTY(name string, err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		var devices []string
+		for _, pattern := range serialDevicePatterns {
+			matches, _ := filepath.Glob(pattern)
+			devices = append(devices, matches...)
+		}
+		if len(devices) == 0 {
+			return fmt.Errorf("serial device %v not found and no other serial devices available, is the receiver plugged in? (%v)", name, err)
+		}
+		return fmt.Errorf("serial device %v not found, use --tty with one of %v (%v)", name, strings.Join(devices, ", "), err)
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("permission denied for serial device %v, add the user to its group, e.g. with 'sudo usermod -aG dialout $USER' (uucp on Arch Linux), and log in again (%v)", name, err)
+	case errors.Is(err, syscall.EBUSY):
+		return fmt.Errorf("serial device %v is busy, another program like gpsd or ModemManager uses it (%v)", name, err)
+	}
+	return fmt.Errorf("can't open serial device %v, %v", name, err)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// redacted replaces secrets in the configuration of /diagnostics
+const redacted = "REDACTED"
+
+// secretFlags are the names of flags whose values are never reported, matched as substrings
+var secretFlags = []string{"token", "password", "secret"}
+
+// fuzzFlags are the flags of the position fuzzing, redacted while fuzzing as the offset would
+// reveal the exact location
+var fuzzFlags = []string{"offset-latitude", "offset-longitude", "fuzz-grid"}
+
+// coordinateFields are the data fields of the latitude and longitude per sentence type, blanked in
+// the raw sentences of /diagnostics while the position is fuzzed
+var coordinateFields = map[string][]int{"GGA": {1, 3}, "RMC": {2, 4}, "GLL": {0, 2}, "GNS": {1, 3}}
+
+// diagnostics is the JSON of /diagnostics, everything support needs in one request
+type diagnostics struct {
+	Version       string
+	Time          time.Time
+	Config        map[string]string
+	Position      data
+	Stats         statistics
+	Sentences     []sentenceInfo
+	LastSentences map[string]string // last raw sentence per type
+	Receiver      receiverInfo
+	Events        []loggedEvent
+}
+
+// version returns the module version and VCS revision the binary was built from
+func version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	v := info.Main.Version
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			v += " " + s.Value
+		}
+	}
+	return v
+}
+
+// redact returns the value of the flag 'name' with secrets removed. Credentials, paths and query
+// parameters of URLs, e.g. of --webhook, are replaced as they often carry tokens, e.g. the paths
+// of Slack and Discord webhooks.
+func redact(name, value string) string {
+	for _, s := range secretFlags {
+		if strings.Contains(name, s) && value != "" {
+			return redacted
+		}
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return value
+	}
+	if u.User != nil {
+		u.User = url.User(redacted)
+	}
+	if u.Path != "" && u.Path != "/" {
+		u.Path, u.RawPath = "/"+redacted, ""
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = redacted
+	}
+	return u.String()
+}
+
+// blankCoordinates returns the raw 'sentences' with the coordinates removed if the position is
+// fuzzed, as they would reveal the exact location. Sentences that can't be split, e.g. with a
+// wrong checksum, are replaced completely.
+func blankCoordinates(sentences map[string]string) map[string]string {
+	if !fuzzing() {
+		return sentences
+	}
+	for typ, sentence := range sentences {
+		fields, ok := coordinateFields[typ]
+		if !ok {
+			continue
+		}
+		if _, err := sentenceFields(sentence); err != nil {
+			sentences[typ] = redacted
+			continue
+		}
+		for _, i := range fields {
+			sentence = replaceField(sentence, i, "")
+		}
+		sentences[typ] = sentence
+	}
+	return sentences
+}
+
+// currentConfig returns the value of every command line option with secrets and, while fuzzing,
+// the fuzzing redacted
+func currentConfig() map[string]string {
+	config := map[string]string{}
+	for _, f := range kingpin.CommandLine.Model().Flags {
+		switch {
+		case f.Name == "help":
+		case fuzzing() && slices.Contains(fuzzFlags, f.Name):
+			config[f.Name] = redacted
+		default:
+			config[f.Name] = redact(f.Name, f.Value.String())
+		}
+	}
+	return config
+}
+
+// HTTP Handler to send the diagnostics bundle as JSON
+func diagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	diag := diagnostics{
+		Version:       version(),
+		Time:          time.Now(),
+		Config:        currentConfig(),
+		Stats:         currentStatistics(),
+		Sentences:     seenSentences.list(),
+		LastSentences: blankCoordinates(seenSentences.lastSentences()),
+		Events:        events.recent(),
+	}
+	d.m.Lock()
+	d.Age = time.Since(d.update)
+	diag.Position = inUnits(d)
+	d.m.Unlock()
+	receiver.m.Lock()
+	diag.Receiver = receiver
+	receiver.m.Unlock()
+
+	js, err := json.Marshal(diag)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
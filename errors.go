@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// errorResponse is the JSON body of all HTTP error responses
+type errorResponse struct {
+	Error string `json:"error"`
+	Code  int    `json:"code"`
+}
+
+// httpError replies to the request with the HTTP status 'code' and 'message' as JSON
+func httpError(w http.ResponseWriter, message string, code int) {
+	js, _ := json.Marshal(errorResponse{Error: message, Code: code})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	w.Write(js)
+}
+
+// allowMethods returns an HTTP Handler that only passes requests with one of the 'methods' to 'h'
+// and replies 405 with an Allow header otherwise. GET implies HEAD.
+func allowMethods(h http.HandlerFunc, methods ...string) http.HandlerFunc {
+	allowed := map[string]bool{}
+	for _, m := range methods {
+		allowed[m] = true
+		if m == http.MethodGet {
+			allowed[http.MethodHead] = true
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	allow := strings.Join(methods, ", ")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowed[r.Method] {
+			w.Header().Set("Allow", allow)
+			httpError(w, fmt.Sprintf("method %v not allowed", r.Method), http.StatusMethodNotAllowed)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// get returns an HTTP Handler for read-only endpoints that only allows GET and HEAD
+func get(h http.HandlerFunc) http.HandlerFunc {
+	return allowMethods(h, http.MethodGet)
+}
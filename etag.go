@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// etag returns the ETag of 'p' as served on / with the decimal places 'precision', -1 by default.
+// It is based on the time 'p' was stored, so Age does not change it.
+func etag(p data, precision int) string {
+	if precision < 0 {
+		return fmt.Sprintf(`"%x"`, p.stored.UnixNano())
+	}
+	return fmt.Sprintf(`"%x-%d"`, p.stored.UnixNano(), precision)
+}
+
+// notModified returns true if the If-None-Match header of 'r' matches 'tag'
+func notModified(r *http.Request, tag string) bool {
+	for _, t := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == tag || t == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Types of the events
+const (
+	eventMoving      = "moving"
+	eventGeofence    = "geofence"
+	eventConnection  = "connection"
+	eventFix         = "fix"
+	eventAntenna     = "antenna"
+	eventFixQuality  = "fix-quality"
+	eventAnchor      = "anchor"
+	eventTrip        = "trip"
+	eventDegradation = "degradation"
+)
+
+// eventQueue is the number of events queued per subscriber of /events?stream=true before new ones
+// are dropped
+const eventQueue = 16
+
+// loggedEvent is an entry of the event log
+type loggedEvent struct {
+	Timestamp time.Time
+	Type      string
+	Details   string
+}
+
+// eventLog holds the last --event-log-size events and distributes new ones to subscribers
+type eventLog struct {
+	m      *sync.Mutex
+	events []loggedEvent
+	subs   map[chan loggedEvent]bool
+}
+
+// events is the event log of /events
+var events = eventLog{
+	m:    &sync.Mutex{},
+	subs: map[chan loggedEvent]bool{},
+}
+
+// event reports an event of type 'typ', e.g. a state change of the GPS data
+func event(typ, format string, args ...interface{}) {
+	e := loggedEvent{Timestamp: time.Now(), Type: typ, Details: fmt.Sprintf(format, args...)}
+	log.Printf("Event %v: %v", e.Type, e.Details)
+	events.add(e)
+}
+
+// add appends 'e' to the log, dropping the oldest event beyond --event-log-size
+func (l *eventLog) add(e loggedEvent) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.events = append(l.events, e)
+	if len(l.events) > *eventLogSize {
+		l.events = l.events[len(l.events)-*eventLogSize:]
+	}
+	for c := range l.subs {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
+
+// recent returns a copy of the logged events, oldest first
+func (l *eventLog) recent() []loggedEvent {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return append([]loggedEvent{}, l.events...)
+}
+
+// subscribe registers a new subscriber. It counts towards --max-subscribers like the subscribers
+// of the hubs.
+func (l *eventLog) subscribe() (chan loggedEvent, error) {
+	n := subscribers.Add(1)
+	if *maxSubscribers > 0 && n > int64(*maxSubscribers) {
+		subscribers.Add(-1)
+		return nil, errTooManySubscribers
+	}
+	c := make(chan loggedEvent, eventQueue)
+	l.m.Lock()
+	l.subs[c] = true
+	l.m.Unlock()
+	return c, nil
+}
+
+// unsubscribe removes the subscriber 'c'
+func (l *eventLog) unsubscribe(c chan loggedEvent) {
+	l.m.Lock()
+	delete(l.subs, c)
+	l.m.Unlock()
+	subscribers.Add(-1)
+}
+
+// eventsHandler responds with the logged events as JSON. With ?stream=true new events are sent as
+// server-sent events instead.
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("stream") == "true" {
+		streamEvents(w, r)
+		return
+	}
+
+	js, err := json.Marshal(events.recent())
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
+
+// streamEvents sends every new event as server-sent event
+func streamEvents(w http.ResponseWriter, r *http.Request) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		httpError(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	c, err := events.subscribe()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer events.unsubscribe(c)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	f.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-c:
+			js, err := json.Marshal(e)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: %v\ndata: %s\n\n", e.Type, js)
+			f.Flush()
+		}
+	}
+}
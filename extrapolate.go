@@ -0,0 +1,23 @@
+package main
+
+import "time"
+
+// extrapolate projects the position of 'p' to 'now' using its speed and course, e.g. for UIs
+// polling a moving vehicle. Without a fix, or if the position is older than --max-extrapolation,
+// 'p' stays unchanged, as does a position snapped to the --fuzz-grid. Without a valid course the
+// asset is considered stationary.
+func extrapolate(p *data, now time.Time) {
+	age := now.Sub(p.Updated.Position)
+	if !p.fix || p.Fuzzed && *fuzzGrid > 0 || p.Updated.Position.IsZero() || age < 0 || age > *maxExtrapolation {
+		return
+	}
+
+	p.Extrapolated = true
+	p.ExtrapolationAge = age
+	if !p.CourseValid {
+		return
+	}
+	dist := p.Speed / 3.6 * age.Seconds()
+	lat, lon := destination(p.Latitude, p.Longitude, p.Course, dist)
+	setCoordinates(p, lat, lon)
+}
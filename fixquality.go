@@ -0,0 +1,112 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Fix qualities of the GGA sentence as exposed in FixQuality
+const (
+	qualityNone      = "none"
+	qualityGPS       = "gps"
+	qualityDGPS      = "dgps"
+	qualityPPS       = "pps"
+	qualityRTKFixed  = "rtk-fixed"
+	qualityRTKFloat  = "rtk-float"
+	qualityEstimated = "estimated"
+	qualityManual    = "manual"
+	qualitySimulated = "simulated"
+)
+
+// fixQualities maps the GGA fix quality field to FixQuality
+var fixQualities = map[string]string{
+	"0": qualityNone,
+	"1": qualityGPS,
+	"2": qualityDGPS,
+	"3": qualityPPS,
+	"4": qualityRTKFixed,
+	"5": qualityRTKFloat,
+	"6": qualityEstimated,
+	"7": qualityManual,
+	"8": qualitySimulated,
+}
+
+// fixQualityRank orders the fix qualities by accuracy for comparing them with --min-fix-quality
+var fixQualityRank = map[string]int{
+	"":               0,
+	qualityNone:      0,
+	qualityEstimated: 1,
+	qualityManual:    1,
+	qualitySimulated: 1,
+	qualityGPS:       2,
+	qualityPPS:       2,
+	qualityDGPS:      3,
+	qualityRTKFloat:  4,
+	qualityRTKFixed:  5,
+}
+
+// qualityAlarm raises an alarm when the fix quality falls below --min-fix-quality, e.g. an RTK
+// fixed solution degrading to float. Raising and clearing it needs to persist for
+// --fix-quality-debounce, so brief float excursions do not flap. It also accumulates the time
+// spent in each fix quality.
+type qualityAlarm struct {
+	m         *sync.Mutex
+	alarm     bool
+	since     time.Time // first time the opposite state was observed, zero if none
+	quality   string    // last fix quality
+	updated   time.Time // time of the last fix quality
+	durations map[string]time.Duration
+}
+
+// fixQualityAlarm is the alarm of --min-fix-quality
+var fixQualityAlarm = qualityAlarm{
+	m:         &sync.Mutex{},
+	durations: map[string]time.Duration{},
+}
+
+// update updates the alarm with the fix quality 'quality' at 'now' and returns whether it is raised
+func (a *qualityAlarm) update(now time.Time, quality string) bool {
+	a.m.Lock()
+	defer a.m.Unlock()
+	if !a.updated.IsZero() {
+		a.durations[a.quality] += now.Sub(a.updated)
+	}
+	a.quality, a.updated = quality, now
+
+	if *minFixQuality == qualityNone {
+		return false
+	}
+	low := fixQualityRank[quality] < fixQualityRank[*minFixQuality]
+	if low == a.alarm {
+		a.since = time.Time{}
+		return a.alarm
+	}
+	if a.since.IsZero() {
+		a.since = now
+	}
+	if now.Sub(a.since) < *fixQualityDebounce {
+		return a.alarm
+	}
+	a.alarm = low
+	a.since = time.Time{}
+	if a.alarm {
+		event(eventFixQuality, "fix quality degraded to %v, below %v", quality, *minFixQuality)
+	} else {
+		event(eventFixQuality, "fix quality recovered to %v", quality)
+	}
+	return a.alarm
+}
+
+// state returns whether the alarm is raised and a copy of the time spent in each fix quality
+func (a *qualityAlarm) state() (bool, map[string]time.Duration) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	durations := map[string]time.Duration{}
+	for q, d := range a.durations {
+		durations[q] = d
+	}
+	if !a.updated.IsZero() {
+		durations[a.quality] += time.Since(a.updated)
+	}
+	return a.alarm, durations
+}
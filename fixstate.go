@@ -0,0 +1,42 @@
+package main
+
+import "time"
+
+// fixState tracks whether there is a valid fix, i.e. the last RMC is valid and the last GGA has a
+// fix quality. Losing and recovering the fix needs to persist for --fix-debounce, so a single
+// void sentence does not report a lost fix.
+type fixState struct {
+	valid bool
+	since time.Time // first time the opposite state was observed, zero if none
+}
+
+// update updates the state with the current fix at 'now' and returns true if it changed
+func (f *fixState) update(now time.Time, valid bool) bool {
+	if valid == f.valid {
+		f.since = time.Time{}
+		return false
+	}
+	if f.since.IsZero() {
+		f.since = now
+	}
+	if now.Sub(f.since) < *fixDebounce {
+		return false
+	}
+	f.valid = valid
+	f.since = time.Time{}
+	return true
+}
+
+// updateFixState reports when the fix is lost or acquired as event and for /stats
+func (u *updater) updateFixState(now time.Time) {
+	if !u.fixState.update(now, u.p.Valid && u.p.fix) {
+		return
+	}
+	if u.fixState.valid {
+		fixAcquiredAt.Store(now.UnixNano())
+		event(eventFix, "fix acquired")
+	} else {
+		fixLostAt.Store(now.UnixNano())
+		event(eventFix, "fix lost")
+	}
+}
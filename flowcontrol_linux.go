@@ -0,0 +1,80 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// crtscts enables RTS/CTS flow control, it is missing in the syscall package
+const crtscts = 0x80000000
+
+// setFlowControl enables hardware (RTS/CTS) or software (XON/XOFF) flow control on the serial
+// device 'name'. tarm/serial always disables flow control when opening the port, so the termios
+// flags are changed afterwards through a second file descriptor of the same device.
+func setFlowControl(name, flow string) error {
+	if flow == flowNone {
+		return nil
+	}
+
+	f, err := os.OpenFile(name, syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var t syscall.Termios
+	if err := ioctl(f.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&t))); err != nil {
+		return err
+	}
+	switch flow {
+	case flowHardware:
+		t.Cflag |= crtscts
+	case flowSoftware:
+		t.Iflag |= syscall.IXON | syscall.IXOFF
+	}
+	return ioctl(f.Fd(), syscall.TCSETS, uintptr(unsafe.Pointer(&t)))
+}
+
+// setModemLines asserts or clears the DTR and RTS lines of the serial device 'name', some modules
+// are held in reset or low-power mode otherwise. lineKeep leaves a line as the driver set it.
+func setModemLines(name, dtr, rts string) error {
+	if dtr == lineKeep && rts == lineKeep {
+		return nil
+	}
+
+	f, err := os.OpenFile(name, syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, l := range []struct {
+		state string
+		bit   int
+	}{{dtr, syscall.TIOCM_DTR}, {rts, syscall.TIOCM_RTS}} {
+		request := uintptr(syscall.TIOCMBIS)
+		switch l.state {
+		case lineKeep:
+			continue
+		case lineOff:
+			request = syscall.TIOCMBIC
+		}
+		bit := l.bit
+		if err := ioctl(f.Fd(), request, uintptr(unsafe.Pointer(&bit))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ioctl is a thin wrapper around the ioctl syscall
+func ioctl(fd, request, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package main
+
+import "fmt"
+
+// setFlowControl is only implemented for Linux. Other platforms only support no flow control.
+func setFlowControl(name, flow string) error {
+	if flow == flowNone {
+		return nil
+	}
+	return fmt.Errorf("flow control %v is not supported on this platform", flow)
+}
+
+// setModemLines is only implemented for Linux. Other platforms keep the lines as the driver set them.
+func setModemLines(name, dtr, rts string) error {
+	if dtr == lineKeep && rts == lineKeep {
+		return nil
+	}
+	return fmt.Errorf("setting DTR and RTS is not supported on this platform")
+}
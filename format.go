@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	nmea "github.com/adrianmo/go-nmea"
+)
+
+// roundCarry rounds 'v' to 'decimals' places and returns the carry if it rounds up to 60,
+// e.g. 59.99996 minutes with 4 decimals are 1 degree and 0 minutes
+func roundCarry(v float64, decimals int) (float64, int) {
+	p := math.Pow(10, float64(decimals))
+	v = math.Round(v*p) / p
+	if v >= 60 {
+		return v - 60, 1
+	}
+	return v, 0
+}
+
+// formatGPS formats 'l' in GPS/NMEA coordinates (DDDMM.mmmm) with 'decimals' decimal places for
+// the minutes. A negative 'decimals' uses the format of the nmea package.
+func formatGPS(l float64, decimals int) string {
+	if decimals < 0 {
+		return nmea.FormatGPS(l)
+	}
+	v := math.Abs(l)
+	degrees := int(math.Floor(v))
+	minutes, carry := roundCarry((v-float64(degrees))*60, decimals)
+	width := 2
+	if decimals > 0 {
+		width += decimals + 1
+	}
+	return fmt.Sprintf("%d%0*.*f", degrees+carry, width, decimals, minutes)
+}
+
+// formatDMS formats 'l' in degrees, minutes, seconds with 'decimals' decimal places for the
+// seconds. A negative 'decimals' uses the format of the nmea package.
+func formatDMS(l float64, decimals int) string {
+	if decimals < 0 {
+		return nmea.FormatDMS(l)
+	}
+	v := math.Abs(l)
+	degrees := int(math.Floor(v))
+	minutes := int(math.Floor((v - float64(degrees)) * 60))
+	seconds, carry := roundCarry((v-float64(degrees))*3600-float64(minutes)*60, decimals)
+	minutes += carry
+	if minutes == 60 {
+		degrees++
+		minutes = 0
+	}
+	return fmt.Sprintf("%d° %d' %.*f\"", degrees, minutes, decimals, seconds)
+}
+
+// setCoordinates sets the position of 'p' to 'lat' and 'lon' with all derived formats
+func setCoordinates(p *data, lat, lon float64) {
+	p.Latitude, p.Longitude = lat, lon
+	p.LatitudeGPS = formatGPS(lat, *gpsPrecision)
+	p.LongitudeGPS = formatGPS(lon, *gpsPrecision)
+	p.LatitudeDMS = formatDMS(lat, *dmsPrecision)
+	p.LongitudeDMS = formatDMS(lon, *dmsPrecision)
+	if *utm {
+		p.UTM = toUTM(lat, lon)
+	}
+	if *geohashPrecision > 0 {
+		p.Geohash = geohash(lat, lon, *geohashPrecision)
+	}
+}
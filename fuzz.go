@@ -0,0 +1,49 @@
+package main
+
+import "math"
+
+// metersPerDegree is the length of a degree of latitude in meters
+const metersPerDegree = math.Pi / 180 * earthRadius
+
+// fuzzing returns true if the position is offset or snapped to a grid for privacy
+func fuzzing() bool {
+	return *offsetLatitude != 0 || *offsetLongitude != 0 || *fuzzGrid > 0
+}
+
+// unfuzzed is the real position of 'd' while it is fuzzed, guarded by the mutex of 'd'. The
+// updater resumes from it and the state file persists it, so the fuzzing is never applied twice.
+var unfuzzed struct {
+	latitude, longitude float64
+}
+
+// unfuzz returns 'p' of 'd' with the real position, 'd' must be locked
+func unfuzz(p data) data {
+	if p.Fuzzed {
+		setCoordinates(&p, unfuzzed.latitude, unfuzzed.longitude)
+		p.Fuzzed = false
+	}
+	return p
+}
+
+// fuzz offsets the position of 'p' by --offset-latitude and --offset-longitude and snaps it to a
+// grid of --fuzz-grid meters, so the feed can be shared without revealing the exact location. The
+// returned data is marked as Fuzzed.
+func fuzz(p data) data {
+	if !fuzzing() {
+		return p
+	}
+	lat := max(-90, min(90, p.Latitude+*offsetLatitude))
+	lon := normalizeLongitude(p.Longitude + *offsetLongitude)
+	if *fuzzGrid > 0 {
+		step := *fuzzGrid / metersPerDegree
+		lat = max(-90, min(90, math.Round(lat/step)*step))
+		// The meridians converge, so the grid is wider in degrees of longitude towards the poles
+		if c := math.Cos(lat * math.Pi / 180); c > 1e-6 {
+			lonStep := step / c
+			lon = normalizeLongitude(math.Round(lon/lonStep) * lonStep)
+		}
+	}
+	setCoordinates(&p, lat, lon)
+	p.Fuzzed = true
+	return p
+}
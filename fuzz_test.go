@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// withOffset sets --offset-latitude and resets 'd' until the end of the test
+func withOffset(t *testing.T, offset float64) {
+	old, oldData := *offsetLatitude, d
+	*offsetLatitude = offset
+	d = data{m: &sync.Mutex{}}
+	t.Cleanup(func() { *offsetLatitude, d = old, oldData })
+}
+
+func TestFuzzAppliedOnce(t *testing.T) {
+	withOffset(t, 1)
+	u := newUpdater()
+	err := u.process(sentence("GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lat := u.p.Latitude
+	store(u.p)
+
+	// After a reconnect the updater resumes from the real position, a GSA alone stores it again
+	u = newUpdater()
+	for _, s := range []string{"GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.1", "GPZDA,123520.00,23,03,1994,00,00"} {
+		err = u.process(sentence(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if u.p.Latitude != lat || u.p.Fuzzed {
+		t.Errorf("updater resumed from %v, fuzzed %v, want the real %v", u.p.Latitude, u.p.Fuzzed, lat)
+	}
+	if p := store(u.p); math.Abs(p.Latitude-(lat+1)) > 1e-9 {
+		t.Errorf("stored latitude %v, want %v offset once", p.Latitude, lat+1)
+	}
+}
+
+func TestStateRealPosition(t *testing.T) {
+	withOffset(t, 1)
+	path := filepath.Join(t.TempDir(), "state.json")
+	err := os.WriteFile(path, []byte(`{"Latitude":48.1,"Longitude":11.5,"Satellites":8}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := snapshot(); math.Abs(p.Latitude-49.1) > 1e-9 || !p.Fuzzed {
+		t.Errorf("restored latitude %v, fuzzed %v, want 49.1", p.Latitude, p.Fuzzed)
+	}
+	if p := realSnapshot(); p.Latitude != 48.1 || p.Fuzzed {
+		t.Errorf("real latitude %v, fuzzed %v, want 48.1", p.Latitude, p.Fuzzed)
+	}
+}
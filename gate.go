@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// rejectedFix returns why the GGA fix with 'hdop' and 'satellites' is not good enough to become
+// the current position, and the counter of the gate. It returns "" if the fix passes --max-hdop
+// and --min-satellites.
+func rejectedFix(hdop float64, satellites int64) (string, *atomic.Int64) {
+	switch {
+	case *maxHDOP > 0 && hdop > *maxHDOP:
+		return fmt.Sprintf("HDOP %v", hdop), &rejectedHDOP
+	case *minSatellites > 0 && satellites < int64(*minSatellites):
+		return fmt.Sprintf("%v satellites", satellites), &rejectedSatellites
+	}
+	return "", nil
+}
+
+// resetSmoothing restarts the moving averages and filters after a rejected fix, so the smoothed
+// values don't average across the gap
+func (u *updater) resetSmoothing() {
+	u.speed.reset()
+	u.heading.reset()
+	u.climb.reset()
+}
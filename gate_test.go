@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestRejectedFixSkipsRMC(t *testing.T) {
+	old := *maxHDOP
+	*maxHDOP = 5
+	defer func() { *maxHDOP = old }()
+	oldRejected := rejectedHDOP.Load()
+	u := newUpdater()
+
+	for _, s := range []string{
+		"GPGGA,123519,4807.038,N,01131.000,E,1,08,20.0,545.4,M,46.9,M,,",
+		"GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W",
+	} {
+		err := u.process(sentence(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := rejectedHDOP.Load() - oldRejected; got != 1 {
+		t.Errorf("rejected %v fixes, want 1", got)
+	}
+	if u.dirty || u.p.Valid || !u.p.update.IsZero() {
+		t.Errorf("RMC of the rejected fix is used, dirty %v, valid %v, updated %v", u.dirty, u.p.Valid, u.p.update)
+	}
+
+	// The next epoch passes the gate
+	for _, s := range []string{
+		"GPGGA,123520,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,",
+		"GPRMC,123520,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W",
+	} {
+		err := u.process(sentence(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !u.dirty || !u.p.Valid || u.p.HDOP != 0.9 {
+		t.Errorf("fix of the next epoch is not used, dirty %v, valid %v, HDOP %v", u.dirty, u.p.Valid, u.p.HDOP)
+	}
+}
+
+func TestRejectedFixResetsSmoothing(t *testing.T) {
+	old := *maxHDOP
+	*maxHDOP = 5
+	defer func() { *maxHDOP = old }()
+	u := newUpdater()
+
+	for _, s := range []string{
+		"GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,",
+		"GPRMC,123519,A,4807.038,N,01131.000,E,010.0,084.4,230394,003.1,W",
+		"GPGGA,123520,4807.038,N,01131.000,E,1,08,20.0,545.4,M,46.9,M,,",
+		"GPRMC,123520,A,4807.038,N,01131.000,E,010.0,084.4,230394,003.1,W",
+		"GPGGA,123521,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,",
+		"GPRMC,123521,A,4807.038,N,01131.000,E,020.0,084.4,230394,003.1,W",
+	} {
+		err := u.process(sentence(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The speed before the rejected fix is not averaged in
+	if want := 20 * knotsToKmh; u.p.SpeedSmoothed != want {
+		t.Errorf("smoothed speed %v after the rejected fix, want %v", u.p.SpeedSmoothed, want)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import "math"
+
+const earthRadius = 6371008.8 // mean earth radius in meters
+
+// distance returns the great-circle distance in meters between two positions in decimal degrees
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	φ1 := lat1 * math.Pi / 180
+	φ2 := lat2 * math.Pi / 180
+	Δφ := (lat2 - lat1) * math.Pi / 180
+	Δλ := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(Δφ/2)*math.Sin(Δφ/2) + math.Cos(φ1)*math.Cos(φ2)*math.Sin(Δλ/2)*math.Sin(Δλ/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// validCoordinates returns true if the latitude is within [-90, 90] and the longitude within [-180, 180]
+func validCoordinates(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
+// mod360 normalizes the angle 'x' in degrees to [0, 360)
+func mod360(x float64) float64 {
+	return math.Mod(math.Mod(x, 360)+360, 360)
+}
+
+// normalizeLongitude normalizes the longitude 'lon' to [-180, 180)
+func normalizeLongitude(lon float64) float64 {
+	return mod360(lon+180) - 180
+}
+
+// extendLongitudes extends the longitude range from 'west' eastwards to 'east' by 'lon'. The range
+// may cross the antimeridian, i.e. west > east. It grows on the side that keeps it smallest.
+func extendLongitudes(west, east, lon float64) (float64, float64) {
+	if mod360(lon-west) <= mod360(east-west) {
+		return west, east
+	}
+	if mod360(west-lon) < mod360(lon-east) {
+		return lon, east
+	}
+	return west, lon
+}
+
+// destination returns the position in decimal degrees reached from 'lat', 'lon' after 'dist' meters
+// on the great circle with the initial bearing 'course' in degrees
+func destination(lat, lon, course, dist float64) (float64, float64) {
+	φ1 := lat * math.Pi / 180
+	λ1 := lon * math.Pi / 180
+	θ := course * math.Pi / 180
+	δ := dist / earthRadius
+
+	φ2 := math.Asin(math.Sin(φ1)*math.Cos(δ) + math.Cos(φ1)*math.Sin(δ)*math.Cos(θ))
+	λ2 := λ1 + math.Atan2(math.Sin(θ)*math.Sin(δ)*math.Cos(φ1), math.Cos(δ)-math.Sin(φ1)*math.Sin(φ2))
+	return φ2 * 180 / math.Pi, normalizeLongitude(λ2 * 180 / math.Pi)
+}
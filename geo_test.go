@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestValidCoordinates(t *testing.T) {
+	tests := []struct {
+		lat, lon float64
+		want     bool
+	}{
+		{0, 0, true},
+		{90, 180, true},
+		{-90, -180, true},
+		{90.000001, 0, false},
+		{-90.000001, 0, false},
+		{0, 180.000001, false},
+		{0, -180.000001, false},
+		{95, 13.4, false},
+		{52.5, 200, false},
+	}
+	for _, tt := range tests {
+		if got := validCoordinates(tt.lat, tt.lon); got != tt.want {
+			t.Errorf("validCoordinates(%v, %v) = %v, want %v", tt.lat, tt.lon, got, tt.want)
+		}
+	}
+}
+
+func TestRejectInvalidCoordinates(t *testing.T) {
+	u := newUpdater()
+	err := u.process(sentence("GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejected := rejectedCoordinates.Load()
+
+	// The nmea parser rejects longitudes beyond 180°, but not latitudes beyond 90°
+	err = u.process(sentence("GPGGA,123520,9500.000,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rejectedCoordinates.Load() - rejected; got != 1 {
+		t.Errorf("rejected %v fixes, want 1", got)
+	}
+	if math.Abs(u.p.Latitude-48.1173) > 1e-9 {
+		t.Errorf("latitude %v after the rejected fix, want 48.1173 of the last valid one", u.p.Latitude)
+	}
+}
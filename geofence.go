@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// geofence is a circular area given by --geofence
+type geofence struct {
+	Name      string
+	Latitude  float64
+	Longitude float64
+	Radius    float64 // in meters
+}
+
+// parseGeofence parses a geofence in the format name:latitude,longitude,radius, e.g.
+// warehouse:52.5163,13.3777,150
+func parseGeofence(s string) (geofence, error) {
+	name, circle, ok := strings.Cut(s, ":")
+	values := strings.Split(circle, ",")
+	if !ok || name == "" || len(values) != 3 {
+		return geofence{}, fmt.Errorf("invalid geofence %v, must be name:latitude,longitude,radius", s)
+	}
+	g := geofence{Name: name}
+	for i, v := range []*float64{&g.Latitude, &g.Longitude, &g.Radius} {
+		var err error
+		*v, err = strconv.ParseFloat(strings.TrimSpace(values[i]), 64)
+		if err != nil {
+			return geofence{}, fmt.Errorf("invalid geofence %v, %v", s, err)
+		}
+	}
+	if !validCoordinates(g.Latitude, g.Longitude) || g.Radius <= 0 {
+		return geofence{}, fmt.Errorf("invalid geofence %v, coordinates out of range or radius not positive", s)
+	}
+	return g, nil
+}
+
+// geofences tracks which of the geofences the position is inside
+type geofences struct {
+	fences []geofence
+	inside map[string]bool
+}
+
+// transitions are the geofences entered and exited with a fix, and those it is inside of
+type transitions struct {
+	Entered []string
+	Exited  []string
+	Inside  []string
+}
+
+// update checks the position of 'p' against the geofences and returns the transitions. Without a
+// fix the last state is kept.
+func (g *geofences) update(p data) transitions {
+	var t transitions
+	if g.inside == nil {
+		g.inside = map[string]bool{}
+	}
+	for _, f := range g.fences {
+		inside := g.inside[f.Name]
+		if p.fix {
+			inside = distance(f.Latitude, f.Longitude, p.Latitude, p.Longitude) <= f.Radius
+		}
+		switch {
+		case inside && !g.inside[f.Name]:
+			t.Entered = append(t.Entered, f.Name)
+			event(eventGeofence, "entered %v", f.Name)
+		case !inside && g.inside[f.Name]:
+			t.Exited = append(t.Exited, f.Name)
+			event(eventGeofence, "exited %v", f.Name)
+		}
+		if inside {
+			t.Inside = append(t.Inside, f.Name)
+		}
+		g.inside[f.Name] = inside
+	}
+	return t
+}
+
+// changed returns true if a geofence was entered or exited
+func (t transitions) changed() bool {
+	return len(t.Entered) > 0 || len(t.Exited) > 0
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// geohashBase32 is the alphabet of geohashes, without a, i, l and o
+const geohashBase32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// maxGeohashPrecision is the highest number of geohash characters, 12 characters are a cell of a
+// few centimeters
+const maxGeohashPrecision = 12
+
+// geohash encodes the position as geohash with 'precision' characters. The bits alternate between
+// longitude and latitude, each bisecting the remaining interval.
+func geohash(lat, lon float64, precision int) string {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+	hash := make([]byte, 0, precision)
+	even := true
+	var c, bits int
+	for len(hash) < precision {
+		r, v := &latRange, lat
+		if even {
+			r, v = &lonRange, lon
+		}
+		c <<= 1
+		if mid := (r[0] + r[1]) / 2; v >= mid {
+			c |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+		even = !even
+		bits++
+		if bits == 5 {
+			hash = append(hash, geohashBase32[c])
+			c, bits = 0, 0
+		}
+	}
+	return string(hash)
+}
+
+// queryGeohashPrecision returns the query parameter geohash-precision, -1 if it is not set
+func queryGeohashPrecision(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("geohash-precision")
+	if v == "" {
+		return -1, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || n > maxGeohashPrecision {
+		return 0, fmt.Errorf("invalid geohash precision %q, must be between 0 and %v", v, maxGeohashPrecision)
+	}
+	return n, nil
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	gpxFlushInterval = 5 * time.Second // Interval for flushing the written track points to disk
+	gpxTail          = 64 * 1024       // Bytes read from the end of an existing file to continue it
+	gpxHead          = 4 * 1024        // Bytes read from the start of an existing file for its first time
+	gpxRetryInterval = time.Minute     // Interval for retrying a failed rollover
+)
+
+// Track rollovers of --track-rollover
+const (
+	rolloverNone  = "none"
+	rolloverDaily = "daily"
+)
+
+// Parts of the GPX file written by --gpx-out
+const (
+	gpxHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="nmea-service" xmlns="http://www.topografix.com/GPX/1/1">
+<trk>
+<trkseg>
+`
+	gpxSegment = "</trkseg>\n<trkseg>\n"
+	gpxFooter  = "</trkseg>\n</trk>\n</gpx>\n"
+)
+
+// gpxFile appends the recorded fixes as track points to the GPX file of --gpx-out. The closing
+// tags are written on shutdown, after a crash the file lacks them until the service continues it.
+// With --track-rollover or --track-gap the file is closed and renamed after its first track point,
+// and a new file is started.
+type gpxFile struct {
+	m       *sync.Mutex
+	path    string
+	f       *os.File // nil after a failed rollover until it is reopened, and once closed
+	w       *bufio.Writer
+	flushed time.Time
+	started time.Time     // time of the first track point, zero if there is none
+	last    data          // last track point, its Timestamp is zero if there is none
+	retry   time.Time     // time of the next attempt after a failed rollover
+	closed  bool          // true once close was called
+	done    chan struct{} // closed by close to stop flush
+}
+
+// gpxOut is the GPX file of --gpx-out, nil if unset
+var gpxOut *gpxFile
+
+// openGPX creates the GPX file 'path' or continues an existing one with a new track segment. The
+// closing tags and anything after the last track point, e.g. of an interrupted write, are cut off.
+func openGPX(path string) (*gpxFile, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	start := gpxHeader
+	var started time.Time
+	if size := info.Size(); size > 0 {
+		started = gpxStarted(f)
+		offset := max(size-gpxTail, 0)
+		tail := make([]byte, size-offset)
+		_, err = f.ReadAt(tail, offset)
+		if err != nil && err != io.EOF {
+			f.Close()
+			return nil, err
+		}
+		end := max(gpxEnd(tail, "</trkpt>"), gpxEnd(tail, "<trkseg>"))
+		if end < 0 {
+			f.Close()
+			return nil, errors.New("not a GPX track written by --gpx-out")
+		}
+		err = f.Truncate(offset + int64(end))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		start = "\n" + gpxSegment
+	}
+	_, err = f.Seek(0, io.SeekEnd)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	g := &gpxFile{m: &sync.Mutex{}, path: path, f: f, w: bufio.NewWriter(f), flushed: time.Now(), started: started}
+	g.w.WriteString(start)
+	return g, g.w.Flush()
+}
+
+// startFlush flushes the written track points every gpxFlushInterval until close, also while no
+// recordable fixes arrive
+func (g *gpxFile) startFlush() {
+	g.done = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(gpxFlushInterval)
+		defer ticker.Stop()
+		var logged time.Time
+		for {
+			select {
+			case <-g.done:
+				return
+			case <-ticker.C:
+			}
+			g.m.Lock()
+			var err error
+			if g.f != nil && time.Since(g.flushed) >= gpxFlushInterval {
+				g.flushed = time.Now()
+				err = g.w.Flush()
+			}
+			g.m.Unlock()
+			if err != nil && time.Since(logged) >= reconnectLogInterval {
+				log.Printf("Error while writing to the GPX file, %v", err)
+				logged = time.Now()
+			}
+		}
+	}()
+}
+
+// gpxStarted returns the time of the first track point of the GPX file 'f', zero if it has none
+func gpxStarted(f *os.File) time.Time {
+	head := make([]byte, gpxHead)
+	n, _ := f.ReadAt(head, 0)
+	head = head[:n]
+	i := bytes.Index(head, []byte("<time>"))
+	if i < 0 {
+		return time.Time{}
+	}
+	head = head[i+len("<time>"):]
+	j := bytes.Index(head, []byte("</time>"))
+	if j < 0 {
+		return time.Time{}
+	}
+	t, _ := time.Parse(time.RFC3339, string(head[:j]))
+	return t
+}
+
+// gpxEnd returns the index after the last 'tag' in 'b', -1 if it does not contain it
+func gpxEnd(b []byte, tag string) int {
+	i := bytes.LastIndex(b, []byte(tag))
+	if i < 0 {
+		return -1
+	}
+	return i + len(tag)
+}
+
+// Publish appends the fix of 'p' if it is recorded to the track as well, and flushes the file every
+// gpxFlushInterval
+func (g *gpxFile) Publish(ctx context.Context, p data) error {
+	if !recordable(p) {
+		return nil
+	}
+	g.m.Lock()
+	defer g.m.Unlock()
+	if g.closed || g.f == nil && time.Now().Before(g.retry) {
+		return nil
+	}
+	if g.f == nil {
+		err := g.reopen()
+		if err != nil {
+			return err
+		}
+	}
+	// The track point is still appended to the original file if the rollover fails
+	var err error
+	if g.rolloverDue(p) && !time.Now().Before(g.retry) {
+		err = g.rollover()
+		if g.f == nil {
+			return err
+		}
+	}
+	if g.started.IsZero() {
+		g.started = p.Timestamp
+	}
+	g.last = p
+	// The elevation is omitted without a plausible altitude
+	var ele string
+	if p.Altitude != nil {
+		ele = fmt.Sprintf("<ele>%.1f</ele>", *p.Altitude)
+	}
+	fmt.Fprintf(g.w, "<trkpt lat=\"%.7f\" lon=\"%.7f\">%v<time>%v</time><sat>%d</sat><hdop>%.1f</hdop></trkpt>\n",
+		p.Latitude, p.Longitude, ele, p.Timestamp.UTC().Format(time.RFC3339), p.Satellites, p.HDOP)
+	if time.Since(g.flushed) < gpxFlushInterval {
+		return err
+	}
+	g.flushed = time.Now()
+	if ferr := g.w.Flush(); ferr != nil {
+		return ferr
+	}
+	return err
+}
+
+// rolloverDue returns true if a new file is started with the track point 'p', on a new day in
+// --timezone with --track-rollover daily or after a gap of --track-gap or --track-gap-distance
+func (g *gpxFile) rolloverDue(p data) bool {
+	if g.started.IsZero() || p.Timestamp.IsZero() {
+		return false
+	}
+	if *trackRollover == rolloverDaily {
+		y1, m1, d1 := g.started.In(location).Date()
+		y2, m2, d2 := p.Timestamp.In(location).Date()
+		if y1 != y2 || m1 != m2 || d1 != d2 {
+			return true
+		}
+	}
+	// After a restart the gap is measured from the first track point of the service
+	if g.last.Timestamp.IsZero() {
+		return false
+	}
+	if *trackGap > 0 && p.Timestamp.Sub(g.last.Timestamp) > *trackGap {
+		return true
+	}
+	return *trackGapDistance > 0 && distance(g.last.Latitude, g.last.Longitude, p.Latitude, p.Longitude) > *trackGapDistance
+}
+
+// rolledPath returns the name of the rolled GPX file 'path' that started at 'started', e.g.
+// track-20261014T081500Z.gpx for track.gpx
+func rolledPath(path string, started time.Time) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + started.UTC().Format("20060102T150405Z") + ext
+}
+
+// rollover closes the current file, renames it after its first track point and starts a new one.
+// If it fails the original file is continued and the rollover is retried after gpxRetryInterval.
+func (g *gpxFile) rollover() error {
+	g.w.WriteString(gpxFooter)
+	err := g.w.Flush()
+	if cerr := g.f.Close(); err == nil {
+		err = cerr
+	}
+	g.f = nil
+	if err == nil {
+		rolled := rolledPath(g.path, g.started)
+		err = os.Rename(g.path, rolled)
+		if err == nil {
+			log.Printf("Rolled the GPX file over to %v", rolled)
+			g.started, g.last = time.Time{}, data{}
+		}
+	}
+	// Without the rename openGPX continues the original file
+	if rerr := g.reopen(); err == nil {
+		err = rerr
+	}
+	if err != nil {
+		g.retry = time.Now().Add(gpxRetryInterval)
+	}
+	return err
+}
+
+// reopen opens the file of 'g' again after a rollover, continuing it if it still exists. On failure
+// it is retried after gpxRetryInterval.
+func (g *gpxFile) reopen() error {
+	n, err := openGPX(g.path)
+	if err != nil {
+		g.retry = time.Now().Add(gpxRetryInterval)
+		return err
+	}
+	g.f, g.w, g.flushed = n.f, n.w, n.flushed
+	if g.started.IsZero() {
+		g.started = n.started
+	}
+	return nil
+}
+
+// close writes the closing tags and closes the file
+func (g *gpxFile) close() error {
+	if g.done != nil {
+		close(g.done)
+	}
+	g.m.Lock()
+	defer g.m.Unlock()
+	g.closed = true
+	if g.f == nil {
+		return nil
+	}
+	g.w.WriteString(gpxFooter)
+	err := g.w.Flush()
+	if err != nil {
+		log.Printf("Error while writing to the GPX file, %v", err)
+	}
+	err = g.f.Close()
+	g.f = nil
+	return err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gpxPoint returns a recordable fix at 't'
+func gpxPoint(t time.Time) data {
+	return data{fix: true, Satellites: 8, Timestamp: t, Latitude: 48.1173, Longitude: 11.5167}
+}
+
+func TestGPXRolloverFailure(t *testing.T) {
+	old := *trackRollover
+	*trackRollover = rolloverDaily
+	defer func() { *trackRollover = old }()
+	path := filepath.Join(t.TempDir(), "track.gpx")
+	g, err := openGPX(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.close()
+	ctx := context.Background()
+	day1 := time.Date(2026, 10, 13, 12, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+
+	// A directory in the way of the rolled file lets the rename fail
+	rolled := rolledPath(path, day1)
+	err = os.MkdirAll(filepath.Join(rolled, "blocked"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g.Publish(ctx, gpxPoint(day1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.Publish(ctx, gpxPoint(day2)); err == nil {
+		t.Error("rollover onto a directory succeeded")
+	}
+	// The original file is continued until the retry
+	err = g.Publish(ctx, gpxPoint(day2.Add(time.Second)))
+	if err != nil {
+		t.Errorf("publish before the retry failed, %v", err)
+	}
+	g.m.Lock()
+	g.w.Flush()
+	g.m.Unlock()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "<trkpt"); n != 3 {
+		t.Errorf("original file has %v track points, want 3", n)
+	}
+
+	os.RemoveAll(rolled)
+	g.m.Lock()
+	g.retry = time.Time{}
+	g.m.Unlock()
+	err = g.Publish(ctx, gpxPoint(day2.Add(2*time.Second)))
+	if err != nil {
+		t.Fatalf("retried rollover failed, %v", err)
+	}
+	b, err = os.ReadFile(rolled)
+	if err != nil {
+		t.Fatalf("rolled file is missing, %v", err)
+	}
+	if !strings.HasSuffix(string(b), gpxFooter) || strings.Count(string(b), "<trkpt") != 3 {
+		t.Errorf("rolled file is not closed with its 3 track points:\n%s", b)
+	}
+}
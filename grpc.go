@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// grpcPositions is the hub for all subscribers of StreamPositions of --grpc-listen
+var grpcPositions = hub{
+	m:      &sync.Mutex{},
+	subs:   map[chan []byte]bool{},
+	encode: encodePosition,
+}
+
+// positionsService is the service Positions of positions.proto
+var positionsService = grpc.ServiceDesc{
+	ServiceName: "nmeaservice.v1.Positions",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "GetPosition", Handler: getPosition},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "StreamPositions", Handler: streamPositions, ServerStreams: true},
+	},
+	Metadata: "positions.proto",
+}
+
+// newGRPCServer returns the gRPC server of --grpc-listen. The messages are encoded by wireCodec, so
+// the service needs no generated code.
+func newGRPCServer() *grpc.Server {
+	s := grpc.NewServer(grpc.ForceServerCodec(wireCodec{}))
+	s.RegisterService(&positionsService, struct{}{})
+	return s
+}
+
+// wireMessage is a message encoded in the protobuf wire format
+type wireMessage []byte
+
+// wireCodec encodes and decodes wireMessage. The requests of positions.proto are empty, so their
+// fields are skipped.
+type wireCodec struct{}
+
+func (wireCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return nil, fmt.Errorf("can't encode %T", v)
+	}
+	return m, nil
+}
+
+func (wireCodec) Unmarshal(b []byte, v interface{}) error {
+	for len(b) > 0 {
+		_, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		n = protowire.ConsumeFieldValue(0, typ, b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func (wireCodec) Name() string {
+	return "proto"
+}
+
+// encodePosition encodes 'p' as message Position of positions.proto
+func encodePosition(p data) ([]byte, error) {
+	var ts []byte
+	ts = appendVarint(ts, 1, uint64(p.Timestamp.Unix()))
+	ts = appendVarint(ts, 2, uint64(p.Timestamp.Nanosecond()))
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendBytes(b, ts)
+	b = appendVarint(b, 2, protowire.EncodeBool(p.fix))
+	b = appendVarint(b, 3, protowire.EncodeBool(p.Valid))
+	b = appendDouble(b, 4, p.Latitude)
+	b = appendDouble(b, 5, p.Longitude)
+	if p.Altitude != nil {
+		b = appendDouble(b, 6, *p.Altitude)
+	}
+	b = appendVarint(b, 7, protowire.EncodeBool(p.AltitudeValid))
+	b = appendDouble(b, 8, p.Speed)
+	b = appendDouble(b, 9, p.Course)
+	b = appendVarint(b, 10, protowire.EncodeBool(p.CourseValid))
+	b = appendVarint(b, 11, protowire.EncodeBool(p.Moving))
+	b = appendVarint(b, 12, uint64(p.Satellites))
+	b = appendDouble(b, 13, p.HDOP)
+	b = protowire.AppendTag(b, 14, protowire.BytesType)
+	b = protowire.AppendString(b, p.FixType)
+	b = appendDouble(b, 15, time.Since(p.update).Seconds())
+	return b, nil
+}
+
+// appendVarint appends the varint field 'num', zero values are omitted like in proto3
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// appendDouble appends the double field 'num', zero values are omitted like in proto3
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+// getPosition handles GetPosition
+func getPosition(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	err := dec(nil)
+	if err != nil {
+		return nil, err
+	}
+	b, err := encodePosition(snapshot())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return wireMessage(b), nil
+}
+
+// streamPositions handles StreamPositions until the client disconnects
+func streamPositions(srv interface{}, stream grpc.ServerStream) error {
+	err := stream.RecvMsg(nil)
+	if err != nil {
+		return err
+	}
+	updates, err := grpcPositions.subscribe()
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	defer grpcPositions.unsubscribe(updates)
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case b := <-updates:
+			err := stream.SendMsg(wireMessage(b))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
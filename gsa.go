@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Fix types of the GSA sentence as exposed in FixType
+const (
+	fixNone = "none"
+	fix2D   = "2d"
+	fix3D   = "3d"
+)
+
+// fixTypes maps the GSA fix type field to FixType
+var fixTypes = map[string]string{
+	"1": fixNone,
+	"2": fix2D,
+	"3": fix3D,
+}
+
+// fixTypeRank orders the fix types for comparing them with thresholds
+var fixTypeRank = map[string]int{
+	"":      0,
+	fixNone: 0,
+	fix2D:   2,
+	fix3D:   3,
+}
+
+// gsa is a single parsed GSA sentence
+type gsa struct {
+	fixType string
+	prns    []int64 // PRNs of the satellites used for the fix
+}
+
+// parseGSA parses a GSA sentence of any talker, the nmea parser only supports GPS
+func parseGSA(sentence string) (gsa, error) {
+	_, _, fields, err := splitSentence(sentence)
+	if err != nil {
+		return gsa{}, err
+	}
+	// NMEA 4.10 appends the system ID to the 17 fields
+	if len(fields) < 17 {
+		return gsa{}, fmt.Errorf("GSA with %v fields", len(fields))
+	}
+
+	g := gsa{fixType: fixTypes[fields[1]]}
+	for _, f := range fields[2:14] {
+		if f == "" {
+			continue
+		}
+		prn, err := parseInt(f)
+		if err != nil {
+			return gsa{}, err
+		}
+		g.prns = append(g.prns, prn)
+	}
+	return g, nil
+}
+
+// gsaCycle collects the consecutive GSA sentences of one cycle
+type gsaCycle struct {
+	fixType string
+	used    []int
+}
+
+// processGSA collects the fix type and the satellites used for the fix from a GSA sentence.
+// Multi-GNSS receivers send consecutive GSA sentences per cycle, one for each system, so the
+// satellites are collected until a different sentence type completes the cycle, see commitGSA.
+func (u *updater) processGSA(sentence string) error {
+	g, err := parseGSA(sentence)
+	if err != nil {
+		return err
+	}
+
+	if u.gsa == nil {
+		u.gsa = &gsaCycle{used: []int{}}
+	}
+	for _, prn := range g.prns {
+		u.gsa.used = append(u.gsa.used, int(prn))
+	}
+	u.gsa.fixType = g.fixType
+	return nil
+}
+
+// commitGSA updates the fix type and the satellites used for the fix from the complete GSA cycle,
+// so a partial cycle never shows only the satellites of some systems
+func (u *updater) commitGSA() {
+	if u.gsa == nil {
+		return
+	}
+	// A new slice is assigned each cycle, the previous one may be shared with 'd'
+	u.p.SatellitesUsed = u.gsa.used
+	u.p.FixType = u.gsa.fixType
+	u.p.Updated.Satellites = time.Now()
+	u.dirty = true
+	if *verbose {
+		log.Printf("Fix type: %v\n", u.gsa.fixType)
+		log.Printf("Satellites used: %v\n", u.gsa.used)
+	}
+	u.gsa = nil
+}
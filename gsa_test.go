@@ -0,0 +1,45 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGSACycle(t *testing.T) {
+	u := newUpdater()
+	for _, s := range []string{
+		"GNGSA,A,3,01,02,03,,,,,,,,,,1.8,0.9,1.5,1",
+		"GNGSA,A,3,65,66,,,,,,,,,,,1.8,0.9,1.5,2",
+	} {
+		err := u.process(sentence(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The cycle is only used once it is complete
+		if u.p.SatellitesUsed != nil || u.dirty {
+			t.Fatalf("satellites used %v within the cycle", u.p.SatellitesUsed)
+		}
+	}
+
+	err := u.process(sentence("GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2, 3, 65, 66}; !slices.Equal(u.p.SatellitesUsed, want) || u.p.FixType != fix3D {
+		t.Errorf("satellites used %v, fix type %v, want %v and 3d", u.p.SatellitesUsed, u.p.FixType, want)
+	}
+
+	// The next cycle starts over
+	for _, s := range []string{
+		"GNGSA,A,2,04,,,,,,,,,,,,1.8,0.9,1.5,1",
+		"GPGGA,123520,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,",
+	} {
+		err := u.process(sentence(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(u.p.SatellitesUsed, []int{4}) || u.p.FixType != fix2D {
+		t.Errorf("satellites used %v, fix type %v of the next cycle, want [4] and 2d", u.p.SatellitesUsed, u.p.FixType)
+	}
+}
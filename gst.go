@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// processGST updates the position error statistics from a GST sentence of any talker, the nmea
+// parser does not support it. Receivers without GST keep the errors nil, empty fields as well.
+func (u *updater) processGST(sentence string) error {
+	_, _, fields, err := splitSentence(sentence)
+	if err != nil {
+		return err
+	}
+	if len(fields) < 8 {
+		return fmt.Errorf("GST with %v fields", len(fields))
+	}
+
+	// Standard deviations of latitude, longitude and altitude in meters are the last three fields
+	errs := make([]*float64, 3)
+	for i := range errs {
+		if fields[5+i] == "" {
+			continue
+		}
+		v, err := parseFloat(fields[5+i])
+		if err != nil {
+			return err
+		}
+		errs[i] = &v
+	}
+	u.p.LatitudeError, u.p.LongitudeError, u.p.AltitudeError = errs[0], errs[1], errs[2]
+	u.dirty = true
+	if *verbose {
+		log.Printf("Position errors: %v\n", fields[5:8])
+	}
+	return nil
+}
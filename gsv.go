@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// gsvTimeout is the maximum duration of a set of GSV sentences, an incomplete set is discarded
+// afterwards. Receivers send a set within one cycle, usually one second.
+const gsvTimeout = 2 * time.Second
+
+// constellations maps the NMEA talker IDs to the satellite systems. Talkers that are not listed,
+// e.g. GN for combined data, are ignored for the per constellation information.
+var constellations = map[string]string{
+	"GP": "gps",
+	"GL": "glonass",
+	"GA": "galileo",
+	"GB": "beidou",
+	"BD": "beidou",
+	"GQ": "qzss",
+	"QZ": "qzss",
+	"GI": "navic",
+}
+
+// constellation returns the satellite system for the NMEA talker ID or "" if it is unknown
+func constellation(talker string) string {
+	return constellations[talker]
+}
+
+// constellationInfo holds the signal information of one satellite system
+type constellationInfo struct {
+	SatellitesInView int64
+	SatellitesUsable int64
+	SNR              float64
+}
+
+// gsv is a single parsed GSV sentence
+type gsv struct {
+	talker  string
+	signal  string // signal ID of NMEA 4.10 and later, "" for older receivers
+	total   int64
+	number  int64
+	inView  int64
+	snrByID map[int64]int64
+}
+
+// parseGSV parses a GSV sentence of any talker, the nmea parser only supports GPS and GLONASS
+func parseGSV(sentence string) (gsv, error) {
+	talker, _, fields, err := splitSentence(sentence)
+	if err != nil {
+		return gsv{}, err
+	}
+	if len(fields) < 3 {
+		return gsv{}, fmt.Errorf("GSV with %v fields", len(fields))
+	}
+
+	g := gsv{talker: talker, snrByID: map[int64]int64{}}
+	if (len(fields)-3)%4 == 1 {
+		g.signal = fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+	}
+	if (len(fields)-3)%4 != 0 {
+		return gsv{}, fmt.Errorf("GSV with %v fields", len(fields))
+	}
+	for i, v := range []*int64{&g.total, &g.number, &g.inView} {
+		*v, err = parseInt(fields[i])
+		if err != nil {
+			return gsv{}, err
+		}
+	}
+	if g.number < 1 || g.number > g.total {
+		return gsv{}, fmt.Errorf("GSV sentence %v of %v", g.number, g.total)
+	}
+
+	// Each satellite consists of PRN, elevation, azimuth and SNR
+	for i := 3; i < len(fields); i += 4 {
+		if fields[i] == "" {
+			continue
+		}
+		prn, err := parseInt(fields[i])
+		if err != nil {
+			return gsv{}, err
+		}
+		snr, err := parseInt(fields[i+3])
+		if err != nil {
+			return gsv{}, err
+		}
+		g.snrByID[prn] = snr
+	}
+	return g, nil
+}
+
+// gsvSet is a set of GSV sentences that is not complete yet
+type gsvSet struct {
+	gsv               // merged sentences, number is the last one received
+	started time.Time // time of the first sentence
+}
+
+// skyView reassembles the multi-sentence GSV messages of all talkers and signals
+type skyView struct {
+	partial  map[string]gsvSet          // sets that are not complete yet
+	complete map[string]map[int64]int64 // last complete set of SNR by PRN
+}
+
+// add adds the GSV sentence 'g' received at 'now' and returns true if this completed a set. The
+// sentences of a set must arrive in order within gsvTimeout, otherwise the set is discarded, so a
+// lost sentence never mixes two cycles.
+func (v *skyView) add(g gsv, now time.Time) bool {
+	if v.partial == nil {
+		v.partial = map[string]gsvSet{}
+		v.complete = map[string]map[int64]int64{}
+	}
+
+	key := g.talker + g.signal
+	p, ok := v.partial[key]
+	switch {
+	case g.number == 1:
+		// A new cycle begins, an incomplete set of the last one is discarded
+		if ok && *verbose {
+			log.Printf("Discarding incomplete GSV set of %v, got %v of %v sentences\n", key, p.number, p.total)
+		}
+		p = gsvSet{gsv: g, started: now}
+	case !ok:
+		// The first sentence of this set was lost
+		return false
+	case p.total != g.total || g.number != p.number+1 || now.Sub(p.started) > gsvTimeout:
+		if *verbose {
+			log.Printf("Discarding GSV set of %v, got %v of %v after %v of %v\n", key, g.number, g.total, p.number, p.total)
+		}
+		delete(v.partial, key)
+		return false
+	default:
+		for prn, snr := range g.snrByID {
+			p.snrByID[prn] = snr
+		}
+		p.number = g.number
+	}
+	if g.number != g.total {
+		v.partial[key] = p
+		return false
+	}
+
+	delete(v.partial, key)
+	v.complete[key] = p.snrByID
+	return true
+}
+
+// constellations returns the signal information of all constellations of the complete sets.
+// Satellites reported on several signals are counted once with their best SNR.
+func (v *skyView) constellations() map[string]constellationInfo {
+	snrs := map[string]map[int64]int64{}
+	for key, set := range v.complete {
+		c := constellation(key[:2])
+		if c == "" {
+			continue
+		}
+		if snrs[c] == nil {
+			snrs[c] = map[int64]int64{}
+		}
+		for prn, snr := range set {
+			if best, ok := snrs[c][prn]; ok && best > snr {
+				continue
+			}
+			snrs[c][prn] = snr
+		}
+	}
+
+	infos := map[string]constellationInfo{}
+	for c, set := range snrs {
+		var info constellationInfo
+		sum := int64(0)
+		for _, snr := range set {
+			info.SatellitesInView++
+			if snr > 0 {
+				info.SatellitesUsable++
+				sum += snr
+			}
+		}
+		if info.SatellitesUsable > 0 {
+			info.SNR = math.Round(float64(sum)/float64(info.SatellitesUsable)*10) / 10
+		}
+		infos[c] = info
+	}
+	return infos
+}
+
+// processGSV updates the constellation information once a set of GSV sentences is complete
+func (u *updater) processGSV(sentence string) error {
+	g, err := parseGSV(sentence)
+	if err != nil {
+		return err
+	}
+	if u.sky.add(g, time.Now()) {
+		u.p.Constellations = u.sky.constellations()
+		u.p.Updated.Satellites = time.Now()
+		u.dirty = true
+		if *verbose {
+			log.Printf("Constellations: %v\n", u.p.Constellations)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConstellation(t *testing.T) {
+	tests := []struct {
+		talker string
+		want   string
+	}{
+		{"GP", "gps"},
+		{"GL", "glonass"},
+		{"GA", "galileo"},
+		{"GB", "beidou"},
+		{"BD", "beidou"},
+		{"GQ", "qzss"},
+		{"QZ", "qzss"},
+		{"GI", "navic"},
+		{"GN", ""},
+		{"XX", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := constellation(tt.talker); got != tt.want {
+			t.Errorf("constellation(%q) = %q, want %q", tt.talker, got, tt.want)
+		}
+	}
+}
+
+func TestSkyViewConstellations(t *testing.T) {
+	var v skyView
+	now := time.Now()
+	for _, s := range []string{
+		"GPGSV,2,1,05,01,40,083,46,02,17,308,40,03,07,344,,04,22,228,42",
+		"GPGSV,2,2,05,05,40,083,30",
+		"GAGSV,1,1,02,11,40,083,20,12,17,308,",
+		// GN is no constellation of its own
+		"GNGSV,1,1,01,21,40,083,50",
+	} {
+		g, err := parseGSV(sentence(s))
+		if err != nil {
+			t.Fatalf("parseGSV(%q) failed, %v", s, err)
+		}
+		v.add(g, now)
+	}
+
+	got := v.constellations()
+	want := map[string]constellationInfo{
+		"gps":     {SatellitesInView: 5, SatellitesUsable: 4, SNR: 39.5},
+		"galileo": {SatellitesInView: 2, SatellitesUsable: 1, SNR: 20},
+	}
+	if len(got) != len(want) {
+		t.Errorf("constellations() = %v, want %v", got, want)
+	}
+	for c, info := range want {
+		if got[c] != info {
+			t.Errorf("constellations()[%q] = %+v, want %+v", c, got[c], info)
+		}
+	}
+}
+
+func TestSkyViewBestSignal(t *testing.T) {
+	// The same satellites on the L1 and L5 signals of NMEA 4.10 are counted once with the best SNR
+	var v skyView
+	now := time.Now()
+	for _, s := range []string{
+		"GPGSV,1,1,02,01,40,083,30,02,17,308,44,1",
+		"GPGSV,1,1,02,01,40,083,40,02,17,308,,8",
+	} {
+		g, err := parseGSV(sentence(s))
+		if err != nil {
+			t.Fatalf("parseGSV(%q) failed, %v", s, err)
+		}
+		v.add(g, now)
+	}
+	want := constellationInfo{SatellitesInView: 2, SatellitesUsable: 2, SNR: 42}
+	if got := v.constellations()["gps"]; got != want {
+		t.Errorf("constellations()[gps] = %+v, want %+v", got, want)
+	}
+}
+
+func TestSkyViewReassembly(t *testing.T) {
+	first := "GPGSV,3,1,09,01,40,083,46,02,17,308,40,03,07,344,41,04,22,228,42"
+	second := "GPGSV,3,2,09,05,40,083,30,06,17,308,31,07,07,344,32,08,22,228,33"
+	third := "GPGSV,3,3,09,09,40,083,20"
+	tests := []struct {
+		name      string
+		sentences []string
+		after     []time.Duration // arrival of each sentence, all at once if nil
+		complete  bool
+		inView    int64
+	}{
+		{"complete", []string{first, second, third}, nil, true, 9},
+		{"dropped fragment", []string{first, third}, nil, false, 0},
+		{"out of order", []string{first, third, second}, nil, false, 0},
+		{"first fragment lost", []string{second, third}, nil, false, 0},
+		{"duplicate fragment", []string{first, second, second, third}, nil, false, 0},
+		{"timeout", []string{first, second, third}, []time.Duration{0, time.Second, 3 * time.Second}, false, 0},
+		// The next cycle begins while the last one is incomplete, only the new one is used
+		{"incomplete before next cycle", []string{first, second, first, second, third}, nil, true, 9},
+		{"next cycle with fewer sentences", []string{first, second, "GPGSV,1,1,02,01,40,083,46,02,17,308,40"}, nil, true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v skyView
+			start := time.Now()
+			complete := false
+			for i, s := range tt.sentences {
+				g, err := parseGSV(sentence(s))
+				if err != nil {
+					t.Fatalf("parseGSV(%q) failed, %v", s, err)
+				}
+				now := start
+				if tt.after != nil {
+					now = start.Add(tt.after[i])
+				}
+				complete = v.add(g, now)
+			}
+			if complete != tt.complete {
+				t.Errorf("complete = %v, want %v", complete, tt.complete)
+			}
+			if got := v.constellations()["gps"].SatellitesInView; got != tt.inView {
+				t.Errorf("%v satellites in view, want %v", got, tt.inView)
+			}
+		})
+	}
+}
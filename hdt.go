@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// processHDT updates the true heading from an HDT sentence of any talker, e.g. HEHDT of a gyro
+// compass, the nmea parser does not support it. An empty heading clears HeadingTrue.
+func (u *updater) processHDT(sentence string) error {
+	_, _, fields, err := splitSentence(sentence)
+	if err != nil {
+		return err
+	}
+	if len(fields) < 2 {
+		return fmt.Errorf("HDT with %v fields", len(fields))
+	}
+	if fields[1] != "T" {
+		return fmt.Errorf("HDT heading is not true but %q", fields[1])
+	}
+
+	u.p.HeadingTrue = nil
+	if fields[0] != "" {
+		h, err := parseFloat(fields[0])
+		if err != nil {
+			return err
+		}
+		h = mod360(h)
+		u.p.HeadingTrue = &h
+	}
+	u.p.Updated.Heading = time.Now()
+	u.dirty = true
+	if *verbose {
+		log.Printf("Heading: %v\n", fields[0])
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	nmea "github.com/adrianmo/go-nmea"
+)
+
+// sentenceHook is custom processing of a raw sentence. 'parsed' is the result of the nmea parser,
+// nil for sentences it does not support, e.g. proprietary ones. 'p' is the GPS data being
+// collected, changes are stored with the next update. An error is counted as parse error.
+type sentenceHook func(sentence string, parsed nmea.Sentence, p *data) error
+
+// sentenceHooks are called for every sentence before the built-in processing
+var sentenceHooks []sentenceHook
+
+// registerSentenceHook adds 'h' to the hooks. It is meant to be called from init() of an additional
+// file, e.g. for a receiver specific sentence or metric, so the service does not need to be forked.
+func registerSentenceHook(h sentenceHook) {
+	sentenceHooks = append(sentenceHooks, h)
+}
+
+// runHooks calls the hooks for 'sentence'. The sentence is only parsed if there are hooks.
+func (u *updater) runHooks(sentence string) {
+	if len(sentenceHooks) == 0 {
+		return
+	}
+	parsed, err := nmea.Parse(sentence)
+	if err != nil {
+		parsed = nil
+	}
+	for _, h := range sentenceHooks {
+		err := runHook(h, sentence, parsed, &u.p)
+		if err != nil {
+			parseError(sentence, err)
+		}
+	}
+}
+
+// runHook calls 'h' and recovers from a panic, so a buggy hook can't stop the processing
+func runHook(h sentenceHook, sentence string, parsed nmea.Sentence, p *data) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("sentence hook panicked, %v", r)
+		}
+	}()
+	return h(sentence, parsed, p)
+}
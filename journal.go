@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// journalSocket is the socket of the native protocol of the systemd journal
+const journalSocket = "/run/systemd/journal/socket"
+
+// Priorities of the journal, as syslog
+const (
+	journalError   = 3
+	journalWarning = 4
+	journalInfo    = 6
+)
+
+// journalFields are the GPS fields attached to every journal entry, updated whenever 'd' is stored
+type journalFields struct {
+	fix        bool
+	latitude   float64
+	longitude  float64
+	satellites int64
+}
+
+// journalGPS holds the journalFields of the last stored data, nil before. It is not guarded by
+// the mutex of 'd', so logging never waits for it.
+var journalGPS atomic.Pointer[journalFields]
+
+// setJournalFields updates the GPS fields of the journal entries from 'p'
+func setJournalFields(p data) {
+	journalGPS.Store(&journalFields{fix: p.fix, latitude: p.Latitude, longitude: p.Longitude, satellites: p.Satellites})
+}
+
+// journal sends each log entry as structured entry to the systemd journal. The GPS fields allow
+// filters like 'journalctl GPS_FIX=0'. The socket is not connected, so the journal may restart.
+type journal struct {
+	conn     *net.UnixConn
+	addr     *net.UnixAddr
+	fallback io.Writer // entries the journal does not accept are written here, e.g. stderr
+}
+
+// openJournal opens a socket for sending to the journal socket 'path'
+func openJournal(path string) (io.Writer, error) {
+	_, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &journal{conn: conn, addr: &net.UnixAddr{Name: path, Net: "unixgram"}, fallback: os.Stderr}, nil
+}
+
+// Write sends the log entry 'b' with its priority and the current GPS fields
+func (j *journal) Write(b []byte) (int, error) {
+	message := strings.TrimRight(string(b), "\n")
+	// The journal has its own timestamps, so the one of the log package is dropped
+	if len(message) > 20 {
+		if _, err := time.Parse("2006/01/02 15:04:05", message[:19]); err == nil {
+			message = message[20:]
+		}
+	}
+	priority := journalInfo
+	switch {
+	case strings.HasPrefix(message, "Error"):
+		priority = journalError
+	case strings.HasPrefix(message, "Warning"):
+		priority = journalWarning
+	}
+
+	var entry bytes.Buffer
+	journalField(&entry, "MESSAGE", message)
+	journalField(&entry, "PRIORITY", fmt.Sprint(priority))
+	journalField(&entry, "SYSLOG_IDENTIFIER", "nmea-service")
+	if f := journalGPS.Load(); f != nil {
+		journalField(&entry, "GPS_FIX", fmt.Sprint(bool2float(f.fix)))
+		if f.fix {
+			journalField(&entry, "GPS_LAT", fmt.Sprint(f.latitude))
+			journalField(&entry, "GPS_LON", fmt.Sprint(f.longitude))
+			journalField(&entry, "GPS_SATS", fmt.Sprint(f.satellites))
+		}
+	}
+	// The entry is not lost if the journal is unavailable or rejects it, e.g. as too large
+	_, err := j.conn.WriteToUnix(entry.Bytes(), j.addr)
+	if err != nil {
+		return j.fallback.Write(b)
+	}
+	return len(b), nil
+}
+
+// journalField appends the field 'name' to the journal entry 'b'. Values with newlines are
+// written with their length as the native protocol requires.
+func journalField(b *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(b, "%v=%v\n", name, value)
+		return
+	}
+	b.WriteString(name + "\n")
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value + "\n")
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "socket")
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := openJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := w.(*journal)
+	fallback := &bytes.Buffer{}
+	j.fallback = fallback
+
+	_, err = j.Write([]byte("2026/10/14 08:15:00 Warning: no fix\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 4096)
+	n, _, err := server.ReadFromUnix(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := string(b[:n])
+	for _, f := range []string{"MESSAGE=Warning: no fix\n", "PRIORITY=4\n", "SYSLOG_IDENTIFIER=nmea-service\n"} {
+		if !strings.Contains(entry, f) {
+			t.Errorf("entry %q lacks %q", entry, f)
+		}
+	}
+
+	// Once the journal is gone the entries go to the fallback
+	server.Close()
+	line := "2026/10/14 08:15:01 Using device /dev/ttyUSB0\n"
+	n, err = j.Write([]byte(line))
+	if err != nil || n != len(line) {
+		t.Errorf("write without journal returned %v, %v", n, err)
+	}
+	if fallback.String() != line {
+		t.Errorf("fallback got %q, want %q", fallback.String(), line)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// rotatingFile is a log file 'name' that is rotated once it exceeds 'maxSize' bytes or is older
+// than 'maxAge'. Rotated files are renamed to name.1, name.2, ... and only 'keep' of them are kept.
+// A zero 'maxSize' or 'maxAge' disables the respective rotation.
+type rotatingFile struct {
+	m       *sync.Mutex
+	name    string
+	maxSize int64
+	maxAge  time.Duration
+	keep    int
+	f       *os.File
+	size    int64
+	opened  time.Time
+}
+
+// openRotatingFile opens or appends to the log file 'name'
+func openRotatingFile(name string, maxSize int64, maxAge time.Duration, keep int) (*rotatingFile, error) {
+	r := &rotatingFile{m: &sync.Mutex{}, name: name, maxSize: maxSize, maxAge: maxAge, keep: keep}
+	return r, r.open()
+}
+
+// open opens the log file for appending
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = fi.Size()
+	r.opened = time.Now()
+	return nil
+}
+
+// rotate closes the current log file, shifts the rotated files and opens a new log file
+func (r *rotatingFile) rotate() error {
+	r.f.Close()
+	os.Remove(fmt.Sprintf("%v.%v", r.name, r.keep))
+	for i := r.keep - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%v.%v", r.name, i), fmt.Sprintf("%v.%v", r.name, i+1))
+	}
+	if r.keep > 0 {
+		os.Rename(r.name, r.name+".1")
+	} else {
+		os.Remove(r.name)
+	}
+	return r.open()
+}
+
+// Write writes 'b' to the log file and rotates it before if needed. It is safe for concurrent use.
+func (r *rotatingFile) Write(b []byte) (int, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if (r.maxSize > 0 && r.size+int64(len(b)) > r.maxSize && r.size > 0) ||
+		(r.maxAge > 0 && time.Since(r.opened) > r.maxAge) {
+		err := r.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+// setupLogging redirects the log output to the log file, syslog and/or the journal if configured
+func setupLogging() error {
+	var writers []io.Writer
+	if *logFile != "" {
+		f, err := openRotatingFile(*logFile, *logMaxSize*1024*1024, *logMaxAge, *logKeep)
+		if err != nil {
+			return err
+		}
+		writers = append(writers, f)
+	}
+	if *useSyslog {
+		w, err := openSyslog()
+		if err != nil {
+			return err
+		}
+		writers = append(writers, w)
+	}
+	if *useJournal {
+		w, err := openJournal(journalSocket)
+		if err != nil {
+			log.Printf("Warning: can't connect to the journal, logging to stderr, %v", err)
+			w = os.Stderr
+		}
+		writers = append(writers, w)
+	}
+
+	if len(writers) > 0 {
+		log.SetOutput(io.MultiWriter(writers...))
+	}
+	return nil
+}
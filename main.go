@@ -1,138 +1,662 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	nmea "github.com/adrianmo/go-nmea"
-	"github.com/tarm/serial"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 const (
-	yearOffset    = 2000            // offset in years for GSP Signal
-	serialTimeout = 5 * time.Second // Timeout for the serial connection
+	retryDelay       = time.Second     // Delay before reading again after a read error
+	fixInvalid       = "0"             // GGA fix quality without a valid position
+	rmcValid         = "A"             // RMC status of a valid position
+	knotsToKmh       = 1.852           // conversion factor from knots to km/h
+	rmcModeField     = 11              // index of the RMC mode indicator field of NMEA 2.3
+	ggaAltitudeField = 8               // index of the GGA altitude field
+	shutdownTimeout  = 5 * time.Second // Timeout for finishing HTTP requests on shutdown
 )
 
+// differentialFixes are the GGA fix qualities with differential corrections, DGPS, RTK fixed and
+// RTK float
+var differentialFixes = map[string]bool{"2": true, "4": true, "5": true}
+
 // data is the struct that holds all relevant GPS information.
 // lowercase variables are ignored during json.Marshal
 type data struct {
-	m            *sync.Mutex
-	update       time.Time
-	Timestamp    time.Time
+	m         *sync.Mutex
+	update    time.Time
+	stored    time.Time // time of the last change, the basis of the ETag
+	fix       bool
+	Timestamp time.Time
+	// TimestampLocal is Timestamp in the time zone given by --timezone
+	TimestampLocal time.Time
+	// Valid is false if the last RMC had status V, e.g. a receiver with time but without a fix
+	Valid        bool
 	Longitude    float64
 	Latitude     float64
 	LongitudeGPS string
 	LatitudeGPS  string
 	LongitudeDMS string
 	LatitudeDMS  string
-	Altitude     float64
-	Satellites   int64
-	Age          time.Duration
+	// UTM is the position in UTM coordinates with --utm, nil otherwise or outside of 80°S to 84°N
+	UTM *utmCoordinate `json:",omitempty"`
+	// Geohash is the position as geohash with --geohash-precision characters, "" if disabled
+	Geohash string `json:",omitempty"`
+	// PositionSource is the talker and type of the sentence that last updated the position, e.g.
+	// "GNGGA" for a combined GNSS fix or "GPGGA" for GPS only
+	PositionSource string `json:",omitempty"`
+	// Altitude is the altitude in meters, nil if the last GGA had no plausible altitude
+	Altitude *float64 `json:",omitempty"`
+	// AltitudeValid is false if the last GGA had no plausible altitude, Altitude is omitted then
+	AltitudeValid bool
+	// AltitudeRelative is the altitude relative to the reference of POST /zero-altitude
+	AltitudeRelative *float64 `json:",omitempty"`
+	// VerticalSpeed is the rate of climb in m/s derived from the GGA altitudes, not measured, and
+	// averaged over the last --speed-window readings
+	VerticalSpeed float64
+	Speed         float64
+	// SpeedSmoothed is the moving average of Speed over the last --speed-window readings
+	SpeedSmoothed float64
+	// Course over ground in degrees, held while CourseValid is false, e.g. when stationary
+	Course      float64
+	CourseValid bool
+	// HeadingSmoothed is the course low-pass filtered with --heading-time-constant, held like Course
+	HeadingSmoothed float64
+	// HeadingTrue is the true heading in degrees from HDT, e.g. of a gyro compass, nil without HDT.
+	// Unlike the course it is where the bow points, also when stationary or drifting.
+	HeadingTrue *float64 `json:",omitempty"`
+	// Moving is true while the asset is moving, see motion
+	Moving bool
+	// NavStatus is the RMC mode indicator of NMEA 2.3, A=autonomous, D=differential, E=estimated,
+	// N=not valid, or "" for older receivers
+	NavStatus  string
+	Satellites int64
+	// SatellitesUsed are the PRNs of the satellites used for the fix according to GSA
+	SatellitesUsed []int
+	FixType        string
+	// HDOP is the horizontal dilution of precision from GGA
+	HDOP float64
+	// Differential is true if the GGA fix quality is DGPS (2), RTK fixed (4) or RTK float (5)
+	Differential bool
+	// FixQuality is the GGA fix quality, e.g. "gps" or "rtk-fixed"
+	FixQuality string
+	// FixQualityAlarm is true while FixQuality is below --min-fix-quality
+	FixQualityAlarm bool
+	// DGPSAge is the age of the differential corrections in seconds from GGA, nil without corrections
+	DGPSAge *float64 `json:",omitempty"`
+	// PositionConsistencyMeters is the distance between the RMC and GGA positions of the same epoch,
+	// nil if one of them has no valid position
+	PositionConsistencyMeters *float64 `json:",omitempty"`
+	// Standard deviations of the position in meters from GST, nil if the receiver does not send it
+	LatitudeError  *float64 `json:",omitempty"`
+	LongitudeError *float64 `json:",omitempty"`
+	AltitudeError  *float64 `json:",omitempty"`
+	Age            time.Duration
+	// Extrapolated is true if the position was projected to the time of the request with ?extrapolate=true
+	Extrapolated     bool          `json:",omitempty"`
+	ExtrapolationAge time.Duration `json:",omitempty"`
+	// Fuzzed is true if the position was offset or snapped to a grid with --offset-latitude,
+	// --offset-longitude or --fuzz-grid
+	Fuzzed bool `json:",omitempty"`
+	// Units is the unit system of --units if it is not metric, SI holds the SI values then
+	Units string    `json:",omitempty"`
+	SI    *siValues `json:",omitempty"`
+	// Updated holds the time of the last update per group of fields
+	Updated   fieldUpdates
+	FromCache bool
+	// Constellations holds the signal information per satellite system, e.g. "gps" or "galileo"
+	Constellations map[string]constellationInfo
+}
+
+// fieldUpdates holds the time of the last update of each group of fields of data, as the sentence
+// types providing them may be sent at different rates or not at all
+type fieldUpdates struct {
+	Position   time.Time // Latitude and Longitude and their formats from GGA
+	Altitude   time.Time // Altitude and AltitudeRelative from GGA
+	Speed      time.Time // Speed, SpeedSmoothed and Course from RMC
+	Satellites time.Time // Satellites from GGA, SatellitesUsed from GSA and Constellations from GSV
+	Heading    time.Time // HeadingTrue from HDT
+	DGPSAge    time.Time // DGPSAge from the last GGA with differential corrections
 }
 
 var (
 	// Command line options parsed via kingpin. These are pointers.
-	verbose  = kingpin.Flag("verbose", "Enable verbose mode.").Bool()
-	tty      = kingpin.Flag("tty", "Serial Connection.").Default("/dev/ttyUSB0").String()
-	baudrate = kingpin.Flag("baudrate", "Baudrate of the Serial Connection.").Default("115200").Int()
-	host     = kingpin.Flag("host", "Host to listen.").Default("localhost").String()
-	port     = kingpin.Flag("port", "Port to listen on.").Default("54321").Int()
+	verbose                  = kingpin.Flag("verbose", "Enable verbose mode.").Bool()
+	source                   = kingpin.Flag("source", "Source of the NMEA sentences (serial, stdin, tcp, bluetooth).").Default(sourceSerial).Enum(sourceSerial, sourceStdin, sourceTCP, sourceBluetooth)
+	tty                      = kingpin.Flag("tty", "Serial Connection, optionally with its own baud rate, e.g. /dev/ttyUSB0@9600.").Default("/dev/ttyUSB0").String()
+	address                  = kingpin.Flag("address", "Address of the NMEA source for --source tcp.").Default("localhost:10110").String()
+	baudrate                 = kingpin.Flag("baudrate", "Baudrate of the Serial Connection.").Default("115200").Int()
+	databits                 = kingpin.Flag("databits", "Data bits of the Serial Connection.").Default("8").Int()
+	parity                   = kingpin.Flag("parity", "Parity of the Serial Connection (none, odd, even, mark, space).").Default("none").Enum("none", "odd", "even", "mark", "space")
+	stopbits                 = kingpin.Flag("stopbits", "Stop bits of the Serial Connection (1, 1.5, 2).").Default("1").Enum("1", "1.5", "2")
+	flowControl              = kingpin.Flag("flow-control", "Flow control of the Serial Connection (none, hardware, software).").Default(flowNone).Enum(flowNone, flowHardware, flowSoftware)
+	dtr                      = kingpin.Flag("dtr", "State of the DTR line of the Serial Connection on connect (keep, on, off).").Default(lineKeep).Enum(lineKeep, lineOn, lineOff)
+	rts                      = kingpin.Flag("rts", "State of the RTS line of the Serial Connection on connect (keep, on, off).").Default(lineKeep).Enum(lineKeep, lineOn, lineOff)
+	initCommands             = kingpin.Flag("init-command", "Command to send to the receiver on every connect, e.g. $PMTK220,1000, can be repeated.").Strings()
+	allowQuery               = kingpin.Flag("allow-query", "Allow POST /query to send arbitrary commands to the receiver.").Bool()
+	queryTimeout             = kingpin.Flag("query-timeout", "Time to wait for the response of the receiver to a command of POST /query.").Default("2s").Duration()
+	readTimeout              = kingpin.Flag("read-timeout", "Timeout for reading from the Serial or TCP Connection.").Default("5s").Duration()
+	maxTimeouts              = kingpin.Flag("max-timeouts", "Number of consecutive read timeouts before reconnecting, 0 to never reconnect.").Default("3").Int()
+	maxConnectRetries        = kingpin.Flag("max-connect-retries", "Number of failed reconnects before exiting with code 3, 0 to retry forever.").Default("0").Int()
+	readBuffer               = kingpin.Flag("read-buffer", "Size of the read buffer in bytes, larger buffers help fast multi-GNSS receivers.").Default("4096").Int()
+	readQueue                = kingpin.Flag("read-queue", "Number of sentences queued between reading and processing.").Default("256").Int()
+	requireChecksum          = kingpin.Flag("require-checksum", "Reject every sentence without a valid *HH checksum.").Bool()
+	replayFile               = kingpin.Flag("replay", "Replay a recorded NMEA log, optionally gzip compressed, instead of reading from --source.").String()
+	replayInterval           = kingpin.Flag("replay-interval", "Delay between the sentences of --replay.").Default("100ms").Duration()
+	replayLoop               = kingpin.Flag("replay-loop", "Start over at the end of --replay.").Bool()
+	degradeDrop              = kingpin.Flag("degrade-drop", "For testing, rate of sentences to drop, between 0 and 1.").Default("0").Float64()
+	degradeCorrupt           = kingpin.Flag("degrade-corrupt", "For testing, rate of sentences to corrupt the checksum of, between 0 and 1.").Default("0").Float64()
+	degradeFixLoss           = kingpin.Flag("degrade-fix-loss", "For testing, rate of GGA and RMC sentences to start a fix loss at, between 0 and 1.").Default("0").Float64()
+	degradeFixLossDuration   = kingpin.Flag("degrade-fix-loss-duration", "For testing, duration of a fix loss of --degrade-fix-loss.").Default("10s").Duration()
+	degradeJump              = kingpin.Flag("degrade-jump", "For testing, rate of GGA sentences to move the position of, between 0 and 1.").Default("0").Float64()
+	degradeJumpDistance      = kingpin.Flag("degrade-jump-distance", "For testing, distance in meters of a position jump of --degrade-jump.").Default("500").Float64()
+	once                     = kingpin.Flag("once", "Print the first fix as JSON to stdout and exit instead of serving it.").Bool()
+	onceTimeout              = kingpin.Flag("once-timeout", "Time to wait for the first fix with --once.").Default("1m").Duration()
+	host                     = kingpin.Flag("host", "Host to listen.").Default("localhost").String()
+	port                     = kingpin.Flag("port", "Port to listen on.").Default("54321").Int()
+	serveHTTP                = kingpin.Flag("http", "Serve HTTP, disable with --no-http to only run the exporters.").Default("true").Bool()
+	serveHTTP2               = kingpin.Flag("http2", "Additionally serve HTTP/2 without TLS (h2c).").Bool()
+	basePath                 = kingpin.Flag("base-path", "Path prefix of all HTTP routes, e.g. /gps behind a reverse proxy.").String()
+	binaryListen             = kingpin.Flag("binary-listen", "Address to serve the GPS data as binary records on, e.g. :10111.").String()
+	grpcListen               = kingpin.Flag("grpc-listen", "Address to serve the gRPC service of positions.proto on, e.g. :50051.").String()
+	maxSubscribers           = kingpin.Flag("max-subscribers", "Maximum number of clients of /stream, --binary-listen and --grpc-listen, 0 for no limit.").Default("100").Int()
+	deltaDistance            = kingpin.Flag("delta-distance", "Distance in meters the position needs to change for /stream?delta=true.").Default("5").Float64()
+	deltaAltitude            = kingpin.Flag("delta-altitude", "Meters the altitude needs to change for /stream?delta=true.").Default("2").Float64()
+	deltaSpeed               = kingpin.Flag("delta-speed", "Speed in km/h the speed needs to change for /stream?delta=true.").Default("1").Float64()
+	deltaFullInterval        = kingpin.Flag("delta-full-interval", "Interval of full snapshots of /stream?delta=true.").Default("5m").Duration()
+	nmeaOutput               = kingpin.Flag("nmea-output", "Serial port or pty to write GGA and RMC sentences regenerated from the GPS data to.").String()
+	nmeaOutputBaudrate       = kingpin.Flag("nmea-output-baudrate", "Baudrate of --nmea-output.").Default("4800").Int()
+	nmeaOutputRate           = kingpin.Flag("nmea-output-rate", "Rate in Hz of the sentences of --nmea-output.").Default("1").Float64()
+	timezone                 = kingpin.Flag("timezone", "IANA time zone of TimestampLocal, e.g. Europe/Berlin.").Default("Local").String()
+	yearPivot                = kingpin.Flag("year-pivot", "Two digit RMC years below the pivot are 20xx, all others 19xx.").Default("80").Int()
+	gpsPrecision             = kingpin.Flag("gps-precision", "Decimal places of the minutes of LatitudeGPS and LongitudeGPS, -1 for the nmea package format.").Default("-1").Int()
+	dmsPrecision             = kingpin.Flag("dms-precision", "Decimal places of the seconds of LatitudeDMS and LongitudeDMS, -1 for the nmea package format.").Default("-1").Int()
+	utm                      = kingpin.Flag("utm", "Additionally report the position in UTM coordinates.").Bool()
+	geohashPrecision         = kingpin.Flag("geohash-precision", "Number of characters of the Geohash of the position, 0 to disable.").Default("0").Int()
+	outputUnits              = kingpin.Flag("units", "Units of the speeds, altitudes and distances of the JSON and plain text output (metric, imperial, nautical).").Default(unitsMetric).Enum(unitsMetric, unitsImperial, unitsNautical)
+	speedWindow              = kingpin.Flag("speed-window", "Number of readings for the moving averages of SpeedSmoothed and VerticalSpeed.").Default("5").Int()
+	courseHoldSpeed          = kingpin.Flag("course-hold-speed", "Speed in km/h below which the last valid course is held.").Default("2").Float64()
+	altitudeSentinels        = kingpin.Flag("altitude-sentinel", "GGA altitude that is a placeholder without vertical solution, can be repeated.").Default("-9999").Float64List()
+	minAltitude              = kingpin.Flag("min-altitude", "Lowest plausible altitude in meters.").Default("-1000").Float64()
+	maxAltitude              = kingpin.Flag("max-altitude", "Highest plausible altitude in meters.").Default("50000").Float64()
+	headingTimeConstant      = kingpin.Flag("heading-time-constant", "Time constant of the low-pass filter of HeadingSmoothed, 0 to disable.").Default("2s").Duration()
+	maxExtrapolation         = kingpin.Flag("max-extrapolation", "Maximum age of a position that is projected with ?extrapolate=true.").Default("5s").Duration()
+	offsetLatitude           = kingpin.Flag("offset-latitude", "Degrees added to the latitude of every served position, e.g. for testing or privacy.").Default("0").Float64()
+	offsetLongitude          = kingpin.Flag("offset-longitude", "Degrees added to the longitude of every served position.").Default("0").Float64()
+	fuzzGrid                 = kingpin.Flag("fuzz-grid", "Snap every served position to a grid of this size in meters, 0 to disable.").Default("0").Float64()
+	movingSpeed              = kingpin.Flag("moving-speed", "Speed in km/h from which on the asset is moving, it stops below half of it.").Default("3").Float64()
+	movingDistance           = kingpin.Flag("moving-distance", "Distance in meters from the rest position from which on the asset is moving.").Default("20").Float64()
+	movingDebounce           = kingpin.Flag("moving-debounce", "Duration a change of Moving needs to persist.").Default("5s").Duration()
+	motionTimeout            = kingpin.Flag("motion-timeout", "Reset Speed, Course and Moving if no valid RMC updated them for this long, 0 to disable.").Default("0").Duration()
+	anchorRadius             = kingpin.Flag("anchor-radius", "Default radius in meters of the anchor watch set with POST /anchor.").Default("50").Float64()
+	anchorDebounce           = kingpin.Flag("anchor-debounce", "Duration the position needs to stay beyond the anchor radius to raise the alarm.").Default("10s").Duration()
+	fixDebounce              = kingpin.Flag("fix-debounce", "Duration losing or acquiring the fix needs to persist before it is reported.").Default("2s").Duration()
+	minFixQuality            = kingpin.Flag("min-fix-quality", "GGA fix quality below which an alarm is raised (none, gps, dgps, rtk-float, rtk-fixed).").Default(qualityNone).Enum(qualityNone, qualityGPS, qualityDGPS, qualityRTKFloat, qualityRTKFixed)
+	fixQualityDebounce       = kingpin.Flag("fix-quality-debounce", "Duration a change of the fix quality alarm needs to persist.").Default("3s").Duration()
+	noiseWindow              = kingpin.Flag("noise-window", "Duration of the fixes of the stationary receiver for /noise.").Default("10m").Duration()
+	eventLogSize             = kingpin.Flag("event-log-size", "Number of recent events kept for /events.").Default("1000").Int()
+	trackSize                = kingpin.Flag("track-size", "Maximum number of recorded track points.").Default("3600").Int()
+	trackMinFix              = kingpin.Flag("track-min-fix", "Minimum fix type of recorded track points (none, 2d, 3d).").Default(fixNone).Enum(fixNone, fix2D, fix3D)
+	trackMinSats             = kingpin.Flag("track-min-sats", "Minimum number of satellites of recorded track points.").Default("0").Int()
+	minReadySats             = kingpin.Flag("min-ready-sats", "Minimum number of satellites for /ready.").Default("0").Int()
+	minReadyFix              = kingpin.Flag("min-ready-fix", "Minimum fix type for /ready (none, 2d, 3d).").Default(fixNone).Enum(fixNone, fix2D, fix3D)
+	maxUpdateRate            = kingpin.Flag("max-update-rate", "Maximum rate in Hz for updating the GPS data, 0 for no limit.").Default("0").Float64()
+	sinkBackpressure         = kingpin.Flag("sink-backpressure", "Policy for the full queue of a slow output (drop-newest, drop-oldest, block).").Default(sinkDropNewest).Enum(sinkDropNewest, sinkDropOldest, sinkBlock)
+	sinkBlockTimeout         = kingpin.Flag("sink-block-timeout", "Maximum time the parsing waits for the full queue of an output with --sink-backpressure block.").Default("100ms").Duration()
+	maxPositionInconsistency = kingpin.Flag("max-position-inconsistency", "Distance in meters between the RMC and GGA positions of an epoch above which a warning is logged.").Default("50").Float64()
+	maxHDOP                  = kingpin.Flag("max-hdop", "Reject fixes with a higher HDOP, 0 to accept all.").Default("0").Float64()
+	minSatellites            = kingpin.Flag("min-satellites", "Reject fixes of fewer satellites, 0 to accept all.").Default("0").Int()
+	stateFile                = kingpin.Flag("state-file", "File to persist the last known position across restarts.").String()
+	sqlitePath               = kingpin.Flag("sqlite", "SQLite database to write the recorded fixes to.").String()
+	gpxOutPath               = kingpin.Flag("gpx-out", "GPX file to append the recorded fixes to as live track.").String()
+	dataDir                  = kingpin.Flag("data-dir", "Writable directory the relative paths of --record, --state-file, --sqlite, --gpx-out and --log-file are resolved against.").String()
+	trackRollover            = kingpin.Flag("track-rollover", "Start a new --gpx-out file on a schedule (none, daily).").Default(rolloverNone).Enum(rolloverNone, rolloverDaily)
+	trackGap                 = kingpin.Flag("track-gap", "Start a new --gpx-out file and trip after a time gap between fixes of more than this, 0 to disable.").Default("0").Duration()
+	trackGapDistance         = kingpin.Flag("track-gap-distance", "Start a new --gpx-out file and trip after a jump between fixes of more than this many meters, 0 to disable.").Default("0").Float64()
+	recordFile               = kingpin.Flag("record", "Record the raw NMEA sentences to this file.").String()
+	recordMaxSize            = kingpin.Flag("record-max-size", "Rotate the recording when it exceeds this size in MB, 0 to disable.").Default("10").Int64()
+	recordKeep               = kingpin.Flag("record-keep", "Number of rotated recordings to keep.").Default("5").Int()
+	recordRing               = kingpin.Flag("record-ring", "Record the last N sentences to --record as memory mapped ring buffer instead of a rotating file, 0 to disable.").Default("0").Int()
+	recordRingReplay         = kingpin.Flag("record-ring-replay", "Replay the sentences recovered from --record-ring on start to restore the last known state.").Bool()
+	recordSync               = kingpin.Flag("record-sync", "Interval of flushing --record-ring to the storage, 0 to leave it to the OS.").Default("10s").Duration()
+	geofenceFlags            = kingpin.Flag("geofence", "Circular geofence as name:latitude,longitude,radius in meters, can be repeated.").Strings()
+	webhookURL               = kingpin.Flag("webhook", "URL to post the position and geofence transitions to.").String()
+	webhookOn                = kingpin.Flag("webhook-on", "Trigger of --webhook (position, geofence).").Default(webhookPosition).Enum(webhookPosition, webhookGeofence)
+	webhookInterval          = kingpin.Flag("webhook-interval", "Interval of --webhook-on position.").Default("1m").Duration()
+	webhookGeohashPrecision  = kingpin.Flag("webhook-geohash-precision", "Suppress position payloads of --webhook in the geohash cell of this precision of the last payload, 0 to disable.").Default("0").Int()
+	webhookMaxInterval       = kingpin.Flag("webhook-max-interval", "Interval of position payloads of --webhook while the position stays in the same geohash cell.").Default("1h").Duration()
+	metrics                  = kingpin.Flag("metrics", "Serve Prometheus metrics on /metrics, disable with --no-metrics.").Default("true").Bool()
+	outputTemplateText       = kingpin.Flag("output-template", "Go text/template of the GPS data served on /custom, e.g. {{.Latitude}};{{.Longitude}}.").PlaceHolder("TEMPLATE").String()
+	logFile                  = kingpin.Flag("log-file", "Write the log to this file instead of stderr.").String()
+	logMaxSize               = kingpin.Flag("log-max-size", "Rotate the log file when it exceeds this size in MB, 0 to disable.").Default("10").Int64()
+	logMaxAge                = kingpin.Flag("log-max-age", "Rotate the log file when it is older than this duration, 0 to disable.").Default("0").Duration()
+	logKeep                  = kingpin.Flag("log-keep", "Number of rotated log files to keep.").Default("3").Int()
+	useSyslog                = kingpin.Flag("syslog", "Send the log to syslog.").Bool()
+	useJournal               = kingpin.Flag("journal", "Send the log to the systemd journal with structured fields.").Bool()
+	// location is the time zone given by --timezone
+	location *time.Location
 	// d is the instance of data that is updated from the GPS sensor and which is marshaled and send via HTTP
 	d = data{
 		m: &sync.Mutex{},
 	}
 )
 
-// updateGPS updates 'd' with the information from the GPS sensor.
-func updateGPS(r io.Reader) {
-	// Use a buffered reader. We do not want to read byte-wise and look for newlines.
-	reader := bufio.NewReader(r)
+// updateGPS updates 'd' with the information from the GPS sensor until reading fails permanently.
+// The sentences are read by readLines in its own go routine and queued, so a slow processing
+// doesn't stall the reading. They are processed in order by this single worker, as every sentence
+// builds on the state left by the previous ones. Read errors are retried after a delay. After
+// --max-timeouts consecutive read timeouts errNoData is returned.
+func updateGPS(in input) error {
+	lines := make(chan string, *readQueue)
+	done := make(chan error, 1)
+	go func() { done <- readLines(in, lines) }()
+
+	// The parsed information is collected by 'u' and stored in 'd' at most with --max-update-rate.
+	// Within each interval only the most recent information is kept.
+	u := newUpdater()
+	stored := time.Time{}
+	interval := updateInterval()
+	flush := func() {
+		// Everything served or written gets the fuzzed position, the updater keeps the real one
+		out := store(u.p)
+		publishSinks(out, u.moved)
+		u.dirty = false
+		u.moved = false
+		stored = time.Now()
+	}
+
+	// A pending update is also stored if the source pauses
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
 
-	// Loop for parsing
+	// Loop for parsing until the reader stopped and all read sentences are processed
 	for {
-		// Read line
-		sentence, err := reader.ReadString('\n')
-		if err != nil {
-			log.Printf("Error while reading from serial, %v", err)
+		var sentence string
+		select {
+		case s, ok := <-lines:
+			if !ok {
+				if u.dirty {
+					flush()
+				}
+				return <-done
+			}
+			sentence = s
+		case <-tick:
+			if u.dirty && time.Since(stored) >= interval {
+				flush()
+			}
 			continue
 		}
 
-		// Strip \r\n from the sentence
-		sentence = strings.TrimSuffix(strings.TrimSuffix(sentence, "\n"), "\r")
-
+		queuedSentences.Add(-1)
+		sentences.Add(1)
+		seenSentences.add(time.Now(), sentence)
+		record(sentence)
 		// Verbose output
 		if *verbose {
 			log.Printf("Raw Sentence: %v\n", sentence)
 		}
 
-		// Parse sentence via nmea parser
-		s, err := nmea.Parse(sentence)
+		queries.match(sentence)
+		u.runHooks(sentence)
+		err := u.process(sentence)
 		if err != nil {
-			log.Printf("Error while parsing '%v', %v", sentence, err)
-			continue
+			parseError(sentence, err)
 		}
+		linkQuality.add(time.Now(), err == nil)
 
-		// Different NMEA types needs to be handled differently
-		switch m := s.(type) {
-		// We collect the timestamp from the GPRMC and also set the last updated here
-		case nmea.GPRMC:
-			d.m.Lock()
-			d.Timestamp = time.Date(
-				yearOffset+m.Date.YY, time.Month(m.Date.MM), m.Date.DD,
-				m.Time.Hour, m.Time.Minute, m.Time.Second, m.Time.Millisecond,
-				time.UTC).Truncate(time.Second)
-			d.update = time.Now()
-			d.m.Unlock()
-			if *verbose {
-				log.Printf("New time %v\n", d.Timestamp)
+		// The motion only lingers while the RMC is missing, e.g. if only GGA arrives
+		if moving := u.p.Moving; resetStaleMotion(&u.p, time.Now()) {
+			if moving {
+				event(eventMoving, "moving changed to false, no speed since %v", *motionTimeout)
 			}
-		// FROM GGA we collect the GPS location information
-		case nmea.GPGGA:
-			d.m.Lock()
-			d.Altitude = m.Altitude
-			d.Longitude = m.Longitude
-			d.Latitude = m.Latitude
-			d.LatitudeGPS = nmea.FormatGPS(m.Latitude)
-			d.LongitudeGPS = nmea.FormatGPS(m.Longitude)
-			d.LatitudeDMS = nmea.FormatDMS(m.Latitude)
-			d.LongitudeDMS = nmea.FormatDMS(m.Longitude)
-			d.Satellites = m.NumSatellites
-			d.m.Unlock()
-			if *verbose {
-				log.Printf("Latitude: %v\n", m.Latitude)
-				log.Printf("Longitude: %v\n", m.Longitude)
-				log.Printf("Altitude: %v\n", m.Altitude)
+			u.motion = motion{}
+			u.speed.reset()
+			u.heading.reset()
+			u.dirty = true
+		}
+
+		// Store the collected information once the update interval has passed
+		if u.dirty && time.Since(stored) >= interval {
+			flush()
+		}
+	}
+}
+
+// newUpdater returns an updater that continues from the data stored in 'd' with the real position
+func newUpdater() updater {
+	return updater{
+		p:       realSnapshot(),
+		speed:   movingAverage{size: *speedWindow},
+		heading: headingFilter{tau: *headingTimeConstant},
+		climb:   climbRate{average: movingAverage{size: *speedWindow}},
+	}
+}
+
+// updater collects the information of the sentences from the GPS sensor
+type updater struct {
+	p       data      // collected information, stored in 'd' by updateGPS
+	dirty   bool      // true if 'p' changed since it was stored
+	moved   bool      // true if the position changed since it was stored
+	sky     skyView   // reassembly of the GSV sentences
+	gsa     *gsaCycle // GSA cycle being collected, nil between cycles
+	zdaYear int       // four digit year of the last ZDA sentence, 0 without ZDA
+	// time of the last GGA rejected by --max-hdop or --min-satellites, the RMC of its epoch is skipped
+	rejected nmea.Time
+	// positions of the last RMC and GGA for comparing them, nil without a valid position
+	rmcPosition *epochPosition
+	ggaPosition *epochPosition
+	speed       movingAverage
+	heading     headingFilter
+	climb       climbRate
+	motion      motion
+	fixState    fixState
+}
+
+// process parses a single NMEA sentence and updates the collected information. It returns an
+// error if the sentence can't be parsed.
+func (u *updater) process(sentence string) error {
+	if *requireChecksum {
+		if _, err := sentenceFields(sentence); err != nil {
+			return err
+		}
+	}
+	if strings.HasPrefix(sentence, "$P") {
+		if ok, err := u.processProprietary(sentence); ok {
+			return err
+		}
+	}
 
-				log.Printf("Satellites: %v\n", m.NumSatellites)
+	// GSV, GSA, GST, ZDA, HDT and TXT are parsed for all talkers here as the nmea parser does not support all of them
+	typ := sentenceType(sentence)
+	if typ != "GSA" {
+		u.commitGSA()
+	}
+	switch typ {
+	case "GSV":
+		return u.processGSV(sentence)
+	case "GSA":
+		return u.processGSA(sentence)
+	case "GST":
+		return u.processGST(sentence)
+	case "ZDA":
+		return u.processZDA(sentence)
+	case "HDT":
+		return u.processHDT(sentence)
+	case "TXT":
+		return u.processTXT(sentence)
+	}
+
+	// Parse sentence via nmea parser
+	s, err := nmea.Parse(sentence)
+	if err != nil {
+		return err
+	}
+	// The nmea parser has own types with the same fields for the combined GNSS talker GN
+	switch m := s.(type) {
+	case nmea.GNRMC:
+		s = nmea.GPRMC(m)
+	case nmea.GNGGA:
+		s = nmea.GPGGA(m)
+	}
+	// The time of both is the first field, its fraction is misparsed by the nmea parser
+	switch m := s.(type) {
+	case nmea.GPRMC:
+		m.Time = withMilliseconds(m.Time, field(sentence, 0))
+		s = m
+	case nmea.GPGGA:
+		m.Time = withMilliseconds(m.Time, field(sentence, 0))
+		s = m
+	}
+
+	// Different NMEA types needs to be handled differently
+	switch m := s.(type) {
+	// We collect the timestamp from the GPRMC and also set the last updated here if it is valid
+	case nmea.GPRMC:
+		// The fix of a rejected GGA must not become fresh or stored through its RMC
+		if m.Time.Valid && m.Time == u.rejected {
+			if *verbose {
+				log.Printf("Skipping RMC of rejected fix at %v\n", m.Time)
 			}
-		// All remaining types are skipped
-		default:
+			return nil
+		}
+		// The milliseconds are kept like with GGA, so the timestamp doesn't jump back at higher rates
+		u.p.Timestamp = time.Date(u.rmcYear(m.Date.YY), time.Month(m.Date.MM), m.Date.DD, 0, 0, 0, 0, time.UTC).Add(timeOfDay(m.Time))
+		u.p.TimestampLocal = u.p.Timestamp.In(location)
+		// A void RMC (status V) still has the time of the receiver but must not make the data look fresh
+		u.p.Valid = m.Validity == rmcValid
+		if u.p.Valid {
+			u.p.update = time.Now()
+			u.p.Updated.Speed = u.p.update
+		}
+		u.updateFixState(time.Now())
+		// Speed is reported in knots. The moving average restarts whenever the fix is lost.
+		u.p.Speed = m.Speed * knotsToKmh
+		if u.p.Valid {
+			u.p.SpeedSmoothed = u.speed.add(u.p.Speed)
+		} else {
+			u.speed.reset()
+			u.p.SpeedSmoothed = 0
+		}
+		// The course is just noise when stationary, so the last valid one is held
+		if u.p.Valid && u.p.Speed >= *courseHoldSpeed {
+			u.p.Course = m.Course
+			u.p.CourseValid = true
+			u.p.HeadingSmoothed = u.heading.add(time.Now(), m.Course)
+		} else {
+			u.p.CourseValid = false
+			u.heading.reset()
+		}
+		// The mode indicator is not supported by the nmea parser
+		u.p.NavStatus = field(sentence, rmcModeField)
+		u.rmcPosition = nil
+		if u.p.Valid {
+			u.rmcPosition = &epochPosition{time: m.Time, latitude: m.Latitude, longitude: m.Longitude}
+		}
+		u.checkConsistency()
+		u.dirty = true
+		if *verbose {
+			log.Printf("New time %v\n", u.p.Timestamp)
+			log.Printf("Speed: %v\n", u.p.Speed)
+			log.Printf("Course: %v\n", m.Course)
+		}
+	// FROM GGA we collect the GPS location information
+	case nmea.GPGGA:
+		// Receivers may glitch and report impossible coordinates
+		if !validCoordinates(m.Latitude, m.Longitude) {
+			log.Printf("Warning: rejecting fix with invalid coordinates %v, %v", m.Latitude, m.Longitude)
+			rejectedCoordinates.Add(1)
+			u.resetSmoothing()
+			return nil
+		}
+		// Low quality fixes, e.g. in urban canyons, are worse than no update
+		if reason, count := rejectedFix(m.HDOP, m.NumSatellites); m.FixQuality != fixInvalid && reason != "" {
 			if *verbose {
-				log.Printf("Skipping %T\n", s)
+				log.Printf("Rejecting fix with %v\n", reason)
+			}
+			count.Add(1)
+			u.rejected = m.Time
+			u.resetSmoothing()
+			return nil
+		}
+		u.rejected = nmea.Time{}
+		// Receivers without vertical solution report placeholders, they are omitted
+		u.p.AltitudeValid = plausibleAltitude(field(sentence, ggaAltitudeField), m.Altitude)
+		u.p.Altitude, u.p.AltitudeRelative = nil, nil
+		if u.p.AltitudeValid {
+			alt := m.Altitude
+			u.p.Altitude = &alt
+			u.p.AltitudeRelative = altitudeZero.relative(m.Altitude)
+		}
+		setCoordinates(&u.p, m.Latitude, m.Longitude)
+		if talker, typ, _, err := splitSentence(sentence); err == nil {
+			u.p.PositionSource = talker + typ
+		}
+		// GGA only has the time of day, the date is taken from the last RMC or ZDA
+		if m.Time.Valid && !u.p.Timestamp.IsZero() {
+			u.setTimestamp(onDateOf(u.p.Timestamp, timeOfDay(m.Time)))
+		}
+		u.p.Satellites = m.NumSatellites
+		u.p.HDOP = m.HDOP
+		u.p.Differential = differentialFixes[m.FixQuality]
+		u.p.FixQuality = fixQualities[m.FixQuality]
+		u.p.FixQualityAlarm = fixQualityAlarm.update(time.Now(), u.p.FixQuality)
+		now := time.Now()
+		// The DGPS age is empty without corrections
+		u.p.DGPSAge = nil
+		if age, err := strconv.ParseFloat(m.DGPSAge, 64); err == nil {
+			u.p.DGPSAge = &age
+			u.p.Updated.DGPSAge = now
+		}
+		u.p.Updated.Position = now
+		u.p.Updated.Altitude = now
+		u.p.Updated.Satellites = now
+		u.p.fix = m.FixQuality != fixInvalid
+		u.ggaPosition = nil
+		if u.p.fix {
+			u.ggaPosition = &epochPosition{time: m.Time, latitude: m.Latitude, longitude: m.Longitude}
+		}
+		u.checkConsistency()
+		u.updateFixState(now)
+		// The vertical speed restarts whenever the fix or the altitude is lost
+		if u.p.fix && u.p.AltitudeValid && m.Time.Valid {
+			u.p.VerticalSpeed = u.climb.add(timeOfDay(m.Time), m.Altitude)
+		} else {
+			u.climb.reset()
+			u.p.VerticalSpeed = 0
+		}
+		anchor.update(now, u.p.fix, m.Latitude, m.Longitude)
+		if u.p.fix {
+			u.p.FromCache = false
+			countFix(now)
+			if u.motion.update(now, u.p.Speed, m.Latitude, m.Longitude) {
+				u.p.Moving = u.motion.moving
+				event(eventMoving, "moving changed to %v", u.p.Moving)
 			}
 		}
+		u.dirty = true
+		u.moved = true
+		if *verbose {
+			log.Printf("Latitude: %v\n", m.Latitude)
+			log.Printf("Longitude: %v\n", m.Longitude)
+			log.Printf("Altitude: %v\n", m.Altitude)
+
+			log.Printf("Satellites: %v\n", m.NumSatellites)
+		}
+	// All remaining types are skipped
+	default:
+		if *verbose {
+			log.Printf("Skipping %T\n", s)
+		}
+	}
+	return nil
+}
+
+// updateInterval returns the minimum interval between two updates of 'd' given by
+// --max-update-rate. A rate of zero means every sentence is stored immediately.
+func updateInterval() time.Duration {
+	if *maxUpdateRate == 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / *maxUpdateRate)
+}
+
+// snapshot returns a copy of 'd'
+func snapshot() data {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return d
+}
+
+// realSnapshot returns a copy of 'd' with the real position instead of the fuzzed one
+func realSnapshot() data {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return unfuzz(d)
+}
+
+// store replaces 'd' with the fuzzed 'p' and returns it. The real position is kept for
+// realSnapshot.
+func store(p data) data {
+	out := fuzz(p)
+	d.m.Lock()
+	defer d.m.Unlock()
+	unfuzzed.latitude, unfuzzed.longitude = p.Latitude, p.Longitude
+	out.m = d.m
+	out.stored = time.Now()
+	d = out
+	if *useJournal {
+		setJournalFields(out)
 	}
+	return out
 }
 
-// HTTP Handler to send 'd' as JSON
+// HTTP Handler to send 'd' as JSON, browsers get the dashboard
 func handler(w http.ResponseWriter, r *http.Request) {
-	// Set age as time duration from last time GPRMC was parsed and now
+	if wantsHTML(r) {
+		dashboardHandler(w, r)
+		return
+	}
+
+	precision, err := queryPrecision(r)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hashPrecision, err := queryGeohashPrecision(r)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Set age as time duration from last time a valid GPRMC was parsed and now
 	d.m.Lock()
 	d.Age = time.Since(d.update)
-	// JSONify
-	js, err := json.Marshal(d)
+	p := d
 	d.m.Unlock()
+	if r.URL.Query().Get("extrapolate") == "true" {
+		extrapolate(&p, time.Now())
+	} else {
+		// The extrapolated position changes with every request, so it has no ETag
+		tag := etag(p, precision)
+		w.Header().Set("ETag", tag)
+		if notModified(r, tag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	p = inUnits(p)
+	if hashPrecision >= 0 {
+		p.Geohash = geohash(p.Latitude, p.Longitude, hashPrecision)
+	}
+	if precision >= 0 {
+		applyPrecision(&p, precision)
+	}
+	// JSONify
+	js, err := json.Marshal(p)
 	if err != nil {
-		http.Error(w, "", http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -144,27 +668,399 @@ func handler(w http.ResponseWriter, r *http.Request) {
 func mainWithError() error {
 	// Parse command line
 	kingpin.Parse()
+	err := resolveDataDir()
+	if err != nil {
+		return err
+	}
+	err = setupLogging()
+	if err != nil {
+		return err
+	}
+	location, err = time.LoadLocation(*timezone)
+	if err != nil {
+		return fmt.Errorf("invalid time zone %v, %v", *timezone, err)
+	}
+	// A baud rate given with the tty overrides --baudrate
+	*tty, *baudrate, err = splitTTY(*tty, *baudrate)
+	if err != nil {
+		return err
+	}
+	// The base path is used as /gps, without trailing slash
+	*basePath = strings.Trim(*basePath, "/")
+	if *basePath != "" {
+		*basePath = "/" + *basePath
+	}
+	if *yearPivot < 0 || *yearPivot > 100 {
+		return fmt.Errorf("invalid year pivot %v, must be between 0 and 100", *yearPivot)
+	}
+	if *speedWindow < 1 {
+		return fmt.Errorf("invalid speed window %v, must be at least 1", *speedWindow)
+	}
+	if *minAltitude > *maxAltitude {
+		return fmt.Errorf("invalid altitude range %v to %v", *minAltitude, *maxAltitude)
+	}
+	if *readBuffer < minReadBuffer {
+		return fmt.Errorf("invalid read buffer %v, must be at least %v bytes", *readBuffer, minReadBuffer)
+	}
+	if *readQueue < 1 {
+		return fmt.Errorf("invalid read queue %v, must be at least 1", *readQueue)
+	}
+	if *trackSize < 1 {
+		return fmt.Errorf("invalid track size %v, must be at least 1", *trackSize)
+	}
+	if *eventLogSize < 1 {
+		return fmt.Errorf("invalid event log size %v, must be at least 1", *eventLogSize)
+	}
+	if *geohashPrecision < 0 || *geohashPrecision > maxGeohashPrecision {
+		return fmt.Errorf("invalid geohash precision %v, must be between 0 and %v", *geohashPrecision, maxGeohashPrecision)
+	}
+	if *webhookGeohashPrecision < 0 || *webhookGeohashPrecision > maxGeohashPrecision {
+		return fmt.Errorf("invalid webhook geohash precision %v, must be between 0 and %v", *webhookGeohashPrecision, maxGeohashPrecision)
+	}
+	if *queryTimeout <= 0 || *queryTimeout > maxQueryTimeout {
+		return fmt.Errorf("invalid query timeout %v, must be positive and at most %v", *queryTimeout, maxQueryTimeout)
+	}
+	if *flowControl == flowHardware && *rts != lineKeep {
+		return fmt.Errorf("--rts can't be set with hardware flow control, which controls RTS")
+	}
+	if *maxHDOP < 0 {
+		return fmt.Errorf("invalid max HDOP %v, must not be negative", *maxHDOP)
+	}
+	if *minSatellites < 0 {
+		return fmt.Errorf("invalid min satellites %v, must not be negative", *minSatellites)
+	}
+	if *maxConnectRetries < 0 {
+		return fmt.Errorf("invalid max connect retries %v, must not be negative", *maxConnectRetries)
+	}
+	if *anchorRadius <= 0 {
+		return fmt.Errorf("invalid anchor radius %v, must be positive", *anchorRadius)
+	}
+	if *nmeaOutputRate <= 0 || *nmeaOutputRate > maxNMEAOutputRate {
+		return fmt.Errorf("invalid NMEA output rate %v, must be positive and at most %vHz", *nmeaOutputRate, maxNMEAOutputRate)
+	}
+	for _, r := range []float64{*degradeDrop, *degradeCorrupt, *degradeFixLoss, *degradeJump} {
+		if r < 0 || r > 1 {
+			return fmt.Errorf("invalid degradation rate %v, must be between 0 and 1", r)
+		}
+	}
+	if *readTimeout <= 0 {
+		return fmt.Errorf("invalid read timeout %v, must be positive", *readTimeout)
+	}
+	if *maxUpdateRate < 0 {
+		return fmt.Errorf("invalid max update rate %v, must not be negative", *maxUpdateRate)
+	}
+	if *sinkBlockTimeout <= 0 {
+		return fmt.Errorf("invalid sink block timeout %v, must be positive", *sinkBlockTimeout)
+	}
+	if *recordRing < 0 {
+		return fmt.Errorf("invalid record ring %v, must not be negative", *recordRing)
+	}
+	if *fuzzGrid < 0 {
+		return fmt.Errorf("invalid fuzz grid %v, must not be negative", *fuzzGrid)
+	}
+	if *trackGap < 0 {
+		return fmt.Errorf("invalid track gap %v, must not be negative", *trackGap)
+	}
+	if *trackGapDistance < 0 {
+		return fmt.Errorf("invalid track gap distance %v, must not be negative", *trackGapDistance)
+	}
+	if *trackRollover != rolloverNone && *gpxOutPath == "" {
+		log.Printf("Warning: --track-rollover %v has no effect without --gpx-out", *trackRollover)
+	}
+	if degrading() {
+		logDegradation()
+	}
 	if *verbose {
 		log.Println("Running in verbose mode.")
+		log.Printf("Using source %v\n", *source)
 		log.Printf("Using tty %v\n", *tty)
+		log.Printf("Using address %v\n", *address)
 		log.Printf("Using baudrate %v\n", *baudrate)
+		log.Printf("Using serial format %v%v%v\n", *databits, strings.ToUpper((*parity)[:1]), *stopbits)
+		log.Printf("Using flow control %v\n", *flowControl)
+		log.Printf("Using DTR %v and RTS %v\n", *dtr, *rts)
+		log.Printf("Using init commands %v\n", *initCommands)
+		log.Printf("Using query %v with timeout %v\n", *allowQuery, *queryTimeout)
+		log.Printf("Using require checksum %v\n", *requireChecksum)
+		log.Printf("Using replay %v\n", *replayFile)
+		log.Printf("Using replay interval %v\n", *replayInterval)
+		log.Printf("Using replay loop %v\n", *replayLoop)
+		log.Printf("Using read timeout %v\n", *readTimeout)
+		log.Printf("Using max connect retries %v\n", *maxConnectRetries)
+		log.Printf("Using max timeouts %v\n", *maxTimeouts)
+		log.Printf("Using once %v with timeout %v\n", *once, *onceTimeout)
 		log.Printf("Using host %v\n", *host)
 		log.Printf("Using port %v\n", *port)
+		log.Printf("Using HTTP/2 %v\n", *serveHTTP2)
+		log.Printf("Using base path %v/\n", *basePath)
+		log.Printf("Using binary listen %v\n", *binaryListen)
+		log.Printf("Using gRPC listen %v\n", *grpcListen)
+		log.Printf("Using read buffer of %v bytes\n", *readBuffer)
+		log.Printf("Using read queue of %v sentences\n", *readQueue)
+		log.Printf("Using max subscribers %v\n", *maxSubscribers)
+		log.Printf("Using delta thresholds %vm, %vm, %vkm/h with full snapshots every %v\n", *deltaDistance, *deltaAltitude, *deltaSpeed, *deltaFullInterval)
+		log.Printf("Using NMEA output %v with %v baud at %vHz\n", *nmeaOutput, *nmeaOutputBaudrate, *nmeaOutputRate)
+		log.Printf("Using timezone %v\n", location)
+		log.Printf("Using year pivot %v\n", *yearPivot)
+		log.Printf("Using UTM %v\n", *utm)
+		log.Printf("Using units %v\n", *outputUnits)
+		log.Printf("Using geohash precision %v\n", *geohashPrecision)
+		log.Printf("Using speed window %v\n", *speedWindow)
+		log.Printf("Using course hold speed %v\n", *courseHoldSpeed)
+		log.Printf("Using plausible altitudes %vm to %vm without %v\n", *minAltitude, *maxAltitude, *altitudeSentinels)
+		log.Printf("Using heading time constant %v\n", *headingTimeConstant)
+		log.Printf("Using max extrapolation %v\n", *maxExtrapolation)
+		if fuzzing() {
+			log.Printf("Using position offset %v°, %v° and fuzz grid %vm\n", *offsetLatitude, *offsetLongitude, *fuzzGrid)
+		}
+		log.Printf("Using moving speed %v\n", *movingSpeed)
+		log.Printf("Using moving distance %v\n", *movingDistance)
+		log.Printf("Using moving debounce %v\n", *movingDebounce)
+		log.Printf("Using motion timeout %v\n", *motionTimeout)
+		log.Printf("Using anchor radius %vm with debounce %v\n", *anchorRadius, *anchorDebounce)
+		log.Printf("Using fix debounce %v\n", *fixDebounce)
+		log.Printf("Using min fix quality %v with debounce %v\n", *minFixQuality, *fixQualityDebounce)
+		log.Printf("Using noise window %v\n", *noiseWindow)
+		log.Printf("Using event log size %v\n", *eventLogSize)
+		log.Printf("Using track size %v\n", *trackSize)
+		log.Printf("Using track min fix %v\n", *trackMinFix)
+		log.Printf("Using track min sats %v\n", *trackMinSats)
+		log.Printf("Using min ready sats %v\n", *minReadySats)
+		log.Printf("Using min ready fix %v\n", *minReadyFix)
+		log.Printf("Using max update rate %vHz\n", *maxUpdateRate)
+		log.Printf("Using sink backpressure %v with block timeout %v\n", *sinkBackpressure, *sinkBlockTimeout)
+		log.Printf("Using max position inconsistency %vm\n", *maxPositionInconsistency)
+		log.Printf("Using max HDOP %v\n", *maxHDOP)
+		log.Printf("Using min satellites %v\n", *minSatellites)
+		log.Printf("Using data directory %v\n", *dataDir)
+		log.Printf("Using state file %v\n", *stateFile)
+		log.Printf("Using SQLite database %v\n", *sqlitePath)
+		log.Printf("Using GPX file %v\n", *gpxOutPath)
+		log.Printf("Using track rollover %v with gaps of %v and %vm\n", *trackRollover, *trackGap, *trackGapDistance)
+		log.Printf("Using recording %v\n", *recordFile)
+		log.Printf("Using record ring %v with replay %v and sync every %v\n", *recordRing, *recordRingReplay, *recordSync)
+		log.Printf("Using geofences %v\n", *geofenceFlags)
+		log.Printf("Using webhook %v on %v every %v\n", *webhookURL, *webhookOn, *webhookInterval)
+		log.Printf("Using webhook geohash precision %v with max interval %v\n", *webhookGeohashPrecision, *webhookMaxInterval)
+	}
+
+	// Restore the last known position and keep it up to date
+	if *stateFile != "" {
+		err := loadState(*stateFile)
+		if err != nil {
+			return err
+		}
+		go saveState(*stateFile)
+	}
+	if *motionTimeout > 0 {
+		go resetStaleData()
+	}
+
+	// Report geofence transitions and positions
+	for _, s := range *geofenceFlags {
+		g, err := parseGeofence(s)
+		if err != nil {
+			return err
+		}
+		hooks.fences.fences = append(hooks.fences.fences, g)
 	}
 
-	// Open Serial Connection
-	c := &serial.Config{Name: *tty, Baud: *baudrate, ReadTimeout: serialTimeout}
-	s, err := serial.OpenPort(c)
+	// Record the raw NMEA sentences, with --record-ring the last ones survive a crash
+	if *recordFile != "" && *recordRing > 0 {
+		ring, err = openRing(*recordFile, *recordRing)
+		if err != nil {
+			return fmt.Errorf("can't open recording %v, %v", *recordFile, err)
+		}
+		defer ring.close()
+		if *recordRingReplay {
+			replayRing(ring)
+		}
+		if *recordSync > 0 {
+			go ring.sync(*recordSync)
+		}
+	} else if *recordFile != "" {
+		recorder, err = openRotatingFile(*recordFile, *recordMaxSize*1024*1024, 0, *recordKeep)
+		if err != nil {
+			return fmt.Errorf("can't open recording %v, %v", *recordFile, err)
+		}
+	}
+
+	// Write the recorded fixes to SQLite
+	if *sqlitePath != "" {
+		positions, err = openSQLite(*sqlitePath)
+		if err != nil {
+			return fmt.Errorf("can't open SQLite database %v, %v", *sqlitePath, err)
+		}
+		defer positions.close()
+	}
+	if *gpxOutPath != "" {
+		gpxOut, err = openGPX(*gpxOutPath)
+		if err != nil {
+			return fmt.Errorf("can't open GPX file %v, %v", *gpxOutPath, err)
+		}
+		gpxOut.startFlush()
+		defer gpxOut.close()
+	}
+
+	if *outputTemplateText != "" {
+		err = parseOutputTemplate()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Register the outputs, they run until shutdown and publish their queued updates before the
+	// databases and files are closed
+	registerSink("stream", &streams, false)
+	registerSink("delta", &deltas, false)
+	registerSink("binary", &records, false)
+	registerSink("grpc", &grpcPositions, false)
+	registerSink("track", &tr, true)
+	registerSink("trip", &currentTrip, true)
+	registerSink("noise", &noise, true)
+	registerSink("webhook", &hooks, true)
+	if positions != nil {
+		registerSink("sqlite", positions, true)
+	}
+	if gpxOut != nil {
+		registerSink("gpx", gpxOut, true)
+	}
+	stopSinks := startSinks()
+	defer stopSinks()
+
+	// Open Serial Connection, stdin or TCP connection
+	in, err := openSource()
 	if err != nil {
 		return err
 	}
 
-	// Run updateGPS to keep 'd' up to date in go routine
-	go updateGPS(s)
+	// The GPS updates and the HTTP server run until they fail or a signal is received
+	errs := make(chan error, 4)
+
+	// Shut down cleanly on SIGINT and SIGTERM
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("Received %v, shutting down", sig)
+		errs <- nil
+	}()
+
+	// Run readGPS to keep 'd' up to date in go routine
+	go func() {
+		err := readGPS(in)
+		if err == io.EOF && (*source == sourceStdin || *replayFile != "") {
+			// stdin was closed or the replay finished, shut down cleanly
+			errs <- nil
+			return
+		}
+		errs <- fmt.Errorf("reading from %v stopped, %w", sourceName(), err)
+	}()
+
+	// Print the first fix instead of serving it
+	if *once {
+		return printFirstFix(errs)
+	}
+
+	// Regenerate NMEA for downstream equipment
+	if *nmeaOutput != "" {
+		go writeNMEA()
+	}
+
+	// Send binary records to the clients of --binary-listen
+	if *binaryListen != "" {
+		l, err := net.Listen("tcp", *binaryListen)
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+		go func() {
+			errs <- fmt.Errorf("binary listener stopped, %v", serveRecords(l))
+		}()
+	}
 
-	// Start HTTP Server
-	http.HandleFunc("/", handler)
-	return http.ListenAndServe(fmt.Sprintf("%v:%v", *host, *port), nil)
+	// Serve the gRPC service of positions.proto on --grpc-listen
+	if *grpcListen != "" {
+		l, err := net.Listen("tcp", *grpcListen)
+		if err != nil {
+			return err
+		}
+		g := newGRPCServer()
+		defer g.Stop()
+		go func() {
+			errs <- fmt.Errorf("gRPC server stopped, %v", g.Serve(l))
+		}()
+	}
+
+	// Start HTTP Server unless running as pure exporter
+	route("/", get(handler))
+	route("/lat", get(plainHandler(func() []float64 { return []float64{d.Latitude} }, true)))
+	route("/lon", get(plainHandler(func() []float64 { return []float64{d.Longitude} }, true)))
+	route("/alt", get(plainHandler(func() []float64 {
+		if d.Altitude == nil {
+			return nil
+		}
+		return []float64{*d.Altitude * units().altitude}
+	}, true)))
+	route("/latlon", get(plainHandler(func() []float64 { return []float64{d.Latitude, d.Longitude} }, true)))
+	route("/speed", get(speedHandler))
+	route("/track", get(trackHandler))
+	route("/trip", get(tripHandler))
+	route("/trip/reset", allowMethods(tripResetHandler, http.MethodPost))
+	route("/bounds", get(boundsHandler))
+	route("/at", get(atHandler))
+	route("/noise", get(noiseHandler))
+	route("/sun", get(sunHandler))
+	route("/receiver", get(receiverHandler))
+	route("/diagnostics", get(diagnosticsHandler))
+	route("/capabilities", get(capabilitiesHandler))
+	route("/events", get(eventsHandler))
+	route("/stats", get(statsHandler))
+	route("/healthz", get(healthHandler))
+	route("/ready", get(readyHandler))
+	route("/quality", get(qualityHandler))
+	route("/sentences", get(sentencesHandler))
+	route("/recordings", get(recordingsHandler))
+	route("/recording", get(recordingHandler))
+	if *metrics {
+		route("/metrics", get(metricsHandler))
+	}
+	if outputTemplate != nil {
+		route("/custom", get(customHandler))
+	}
+	route("/stream", get(streamHandler))
+	route("/dashboard", get(dashboardHandler))
+	route("/favicon.ico", get(faviconHandler))
+	route("/zero-altitude", allowMethods(zeroAltitudeHandler, http.MethodPost))
+	if *allowQuery {
+		route("/query", allowMethods(queryHandler, http.MethodPost))
+	}
+	route("/anchor", allowMethods(anchorHandler, http.MethodGet, http.MethodPost, http.MethodDelete))
+	if !*serveHTTP {
+		return <-errs
+	}
+	var routes http.Handler = http.DefaultServeMux
+	if *basePath != "" {
+		// Behind a reverse proxy all routes are served below --base-path, /gps redirects to /gps/
+		mux := http.NewServeMux()
+		mux.Handle(*basePath+"/", http.StripPrefix(*basePath, http.DefaultServeMux))
+		routes = mux
+	}
+	server := &http.Server{Addr: fmt.Sprintf("%v:%v", *host, *port), Handler: routes}
+	if *serveHTTP2 {
+		// Clients which negotiate HTTP/2 without TLS are upgraded, all others keep HTTP/1.1
+		server.Handler = h2c.NewHandler(routes, &http2.Server{})
+	}
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+	err = <-errs
+
+	// Give running requests some time to finish
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	server.Shutdown(ctx)
+	return err
 }
 
 // main calls mainWithError and log error
@@ -172,6 +1068,9 @@ func main() {
 	err := mainWithError()
 	if err != nil {
 		fmt.Println(err)
+		if errors.Is(err, errRetriesExceeded) {
+			os.Exit(exitRetriesExceeded)
+		}
 		os.Exit(1)
 	}
 }
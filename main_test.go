@@ -0,0 +1,102 @@
+package main
+
+import (
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// TestMain sets the flags to their defaults, tests change them where needed
+func TestMain(m *testing.M) {
+	_, err := kingpin.CommandLine.Parse(nil)
+	if err != nil {
+		panic(err)
+	}
+	location = time.UTC
+	os.Exit(m.Run())
+}
+
+// sentence returns the NMEA sentence of 'body', which is without '$' and checksum
+func sentence(body string) string {
+	return "$" + body + "*" + checksum(body)
+}
+
+func TestDGPSAgeUpdated(t *testing.T) {
+	u := newUpdater()
+	err := u.process(sentence("GPGGA,123519,4807.038,N,01131.000,E,2,08,0.9,545.4,M,46.9,M,3.2,0120"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := u.p.Updated.DGPSAge
+	if u.p.DGPSAge == nil || *u.p.DGPSAge != 3.2 || updated.IsZero() {
+		t.Fatalf("DGPS age %v updated %v, want 3.2", u.p.DGPSAge, updated)
+	}
+
+	// Without corrections the time of the last DGPS age is kept
+	err = u.process(sentence("GPGGA,123520,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.p.DGPSAge != nil || !u.p.Updated.DGPSAge.Equal(updated) {
+		t.Errorf("DGPS age %v updated %v, want none updated %v", u.p.DGPSAge, u.p.Updated.DGPSAge, updated)
+	}
+}
+
+// withUpdateRate sets --max-update-rate and resets 'd' until the end of the test
+func withUpdateRate(t *testing.T, rate float64) {
+	old, oldData := *maxUpdateRate, d
+	*maxUpdateRate = rate
+	d = data{m: &sync.Mutex{}}
+	t.Cleanup(func() { *maxUpdateRate, d = old, oldData })
+}
+
+func TestUpdateFlushedAtEnd(t *testing.T) {
+	withUpdateRate(t, 0.001)
+	first := sentence("GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,")
+	last := sentence("GPGGA,123520,4808.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,")
+	in := input{ReadCloser: io.NopCloser(strings.NewReader(first + "\r\n" + last + "\r\n"))}
+	err := updateGPS(in)
+	if err != io.EOF {
+		t.Fatalf("updateGPS returned %v, want EOF", err)
+	}
+	// The last sentence arrived within the interval of the first, it is stored at the end
+	if p := snapshot(); math.Abs(p.Latitude-(48+8.038/60)) > 1e-9 {
+		t.Errorf("latitude %v, want the one of the last sentence", p.Latitude)
+	}
+}
+
+func TestUpdateFlushedOnPause(t *testing.T) {
+	withUpdateRate(t, 20)
+	setBackpressure(t, sinkDropNewest, 0)
+	s := &slowSink{m: &sync.Mutex{}}
+	registerSink("stream", s, false)
+	stop := startSinks()
+	r, w := io.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- updateGPS(input{ReadCloser: r}) }()
+
+	for _, body := range []string{
+		"GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,",
+		"GPGGA,123520,4807.038,N,01131.000,E,1,09,0.9,545.4,M,46.9,M,,",
+	} {
+		_, err := io.WriteString(w, sentence(body)+"\r\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// No more sentences arrive, the pending update is published by the ticker
+	got := s.received(2)
+	w.Close()
+	<-done
+	stop()
+	if !slices.Equal(got, []int64{8, 9}) {
+		t.Errorf("stream received %v while the source paused, want 8 and 9", got)
+	}
+}
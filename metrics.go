@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// metric is a single gauge or counter in the Prometheus text format. Without 'label' the metric
+// has a single value in values[""], otherwise one value per label value.
+type metric struct {
+	name   string
+	help   string
+	typ    string
+	label  string
+	values map[string]float64
+}
+
+// write writes the metric in the Prometheus text format to 'w'
+func (m metric) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %v %v\n", m.name, m.help)
+	fmt.Fprintf(w, "# TYPE %v %v\n", m.name, m.typ)
+	if m.label == "" {
+		fmt.Fprintf(w, "%v %v\n", m.name, m.values[""])
+		return
+	}
+
+	keys := make([]string, 0, len(m.values))
+	for k := range m.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%v{%v=%q} %v\n", m.name, m.label, k, m.values[k])
+	}
+}
+
+// gauge returns an unlabeled metric with the value 'v'
+func gauge(name, help string, v float64) metric {
+	return metric{name: name, help: help, typ: "gauge", values: map[string]float64{"": v}}
+}
+
+// counter returns an unlabeled counter with the value 'v'
+func counter(name, help string, v int64) metric {
+	return metric{name: name, help: help, typ: "counter", values: map[string]float64{"": float64(v)}}
+}
+
+// constellationGauge returns a metric labeled by constellation. All known constellations are
+// reported, with zero if they are not in view, which keeps the label cardinality bounded.
+func constellationGauge(name, help string, p data, value func(constellationInfo) float64) metric {
+	m := metric{name: name, help: help, typ: "gauge", label: "constellation", values: map[string]float64{}}
+	for _, c := range constellations {
+		m.values[c] = value(p.Constellations[c])
+	}
+	return m
+}
+
+// sinkMetric returns a metric labeled by sink with the values per sink
+func sinkMetric(name, help, typ string, values map[string]int64) metric {
+	m := metric{name: name, help: help, typ: typ, label: "sink", values: map[string]float64{}}
+	for s, v := range values {
+		m.values[s] = float64(v)
+	}
+	return m
+}
+
+// bool2float converts true to 1 and false to 0
+func bool2float(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// HTTP Handler to send the GPS data as Prometheus metrics
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	p := snapshot()
+	altitude := math.NaN()
+	if p.Altitude != nil {
+		altitude = *p.Altitude
+	}
+	metrics := []metric{
+		gauge("nmea_fix", "1 if the GPS sensor has a fix, 0 otherwise.", bool2float(p.fix)),
+		gauge("nmea_fix_satellites", "Number of satellites used for the fix.", float64(p.Satellites)),
+		gauge("nmea_altitude_meters", "Altitude in meters, NaN without a plausible altitude.", altitude),
+		gauge("nmea_speed_kmh", "Speed over ground in km/h.", p.Speed),
+		gauge("nmea_age_seconds", "Seconds since the last update of the GPS data.", time.Since(p.update).Seconds()),
+		counter("nmea_rejected_coordinates_total", "Number of fixes rejected due to out of range coordinates.",
+			rejectedCoordinates.Load()),
+		counter("nmea_rejected_hdop_total", "Number of fixes rejected due to an HDOP above --max-hdop.", rejectedHDOP.Load()),
+		counter("nmea_rejected_satellites_total", "Number of fixes rejected due to fewer satellites than --min-satellites.",
+			rejectedSatellites.Load()),
+		counter("nmea_webhooks_posted_total", "Number of payloads posted to --webhook.", webhooksPosted.Load()),
+		counter("nmea_webhooks_suppressed_total", "Number of position payloads suppressed in the same geohash cell.",
+			webhooksSuppressed.Load()),
+		counter("nmea_dropped_sentences_total", "Number of sentences dropped as the processing could not keep up.",
+			droppedSentences.Load()),
+		gauge("nmea_queued_sentences", "Number of sentences read but not processed yet.", float64(queuedSentences.Load())),
+		constellationGauge("nmea_satellites", "Number of usable satellites per constellation.", p,
+			func(c constellationInfo) float64 { return float64(c.SatellitesUsable) }),
+		constellationGauge("nmea_satellites_in_view", "Number of satellites in view per constellation.", p,
+			func(c constellationInfo) float64 { return float64(c.SatellitesInView) }),
+		constellationGauge("nmea_snr_avg", "Average SNR in dB-Hz of the usable satellites per constellation.", p,
+			func(c constellationInfo) float64 { return c.SNR }),
+	}
+	metrics = append(metrics,
+		sinkMetric("nmea_sink_queued_updates", "Number of updates queued per output.", "gauge", queuedUpdates()),
+		sinkMetric("nmea_sink_dropped_updates_total", "Number of updates dropped per output as it was too slow.", "counter",
+			droppedUpdates()))
+	if c, ok := serialErrors(); ok {
+		metrics = append(metrics, metric{
+			name:  "nmea_serial_errors_total",
+			help:  "Number of errors of the serial driver per type.",
+			typ:   "counter",
+			label: "type",
+			values: map[string]float64{
+				"frame":          float64(c.Frame),
+				"overrun":        float64(c.Overrun),
+				"parity":         float64(c.Parity),
+				"break":          float64(c.Break),
+				"buffer_overrun": float64(c.BufferOverrun),
+			},
+		})
+	}
+
+	var b strings.Builder
+	for _, m := range metrics {
+		m.write(&b)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write([]byte(b.String()))
+}
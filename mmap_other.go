@@ -0,0 +1,24 @@
+//go:build windows || plan9
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// mapFile is only implemented for Unix, --record-ring fails to open on other platforms
+func mapFile(f *os.File, size int) ([]byte, error) {
+	return nil, fmt.Errorf("memory mapped files are not supported on %v", runtime.GOOS)
+}
+
+// syncMap is never called as mapFile fails
+func syncMap(b []byte) error {
+	return nil
+}
+
+// unmapFile is never called as mapFile fails
+func unmapFile(b []byte) error {
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// mapFile maps the first 'size' bytes of 'f' into memory, writes to it go to the file
+func mapFile(f *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
+
+// syncMap flushes the mapped 'b' to the storage. Unlike fsync of the file, msync also covers the
+// pages written through the mapping on every platform.
+func syncMap(b []byte) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), syscall.MS_SYNC)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// unmapFile removes the mapping 'b'
+func unmapFile(b []byte) error {
+	return syscall.Munmap(b)
+}
@@ -0,0 +1,48 @@
+package main
+
+import "time"
+
+// motion derives whether the asset is moving. It starts moving when the speed reaches --moving-speed
+// or the position moved more than --moving-distance from where it came to rest. It stops when the
+// speed falls below half of --moving-speed. Both changes need to persist for --moving-debounce so
+// GPS noise does not toggle the state.
+type motion struct {
+	moving  bool
+	since   time.Time // first time the opposite state was observed, zero if none
+	rested  bool      // true if the anchor is set
+	restLat float64   // position where the asset came to rest
+	restLon float64
+}
+
+// update updates the state with the current speed in km/h and position at 'now' and returns true
+// if it changed
+func (m *motion) update(now time.Time, speed, lat, lon float64) bool {
+	if !m.rested {
+		m.rested, m.restLat, m.restLon = true, lat, lon
+	}
+
+	var indicator bool
+	if m.moving {
+		indicator = speed >= *movingSpeed/2
+	} else {
+		indicator = speed >= *movingSpeed || distance(m.restLat, m.restLon, lat, lon) > *movingDistance
+	}
+
+	if indicator == m.moving {
+		m.since = time.Time{}
+		return false
+	}
+	if m.since.IsZero() {
+		m.since = now
+	}
+	if now.Sub(m.since) < *movingDebounce {
+		return false
+	}
+
+	m.moving = indicator
+	m.since = time.Time{}
+	if !m.moving {
+		m.restLat, m.restLon = lat, lon
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// maxNMEAOutputRate is the highest --nmea-output-rate in Hz, the sentences are written at most
+// every millisecond
+const maxNMEAOutputRate = 1000
+
+// nmeaCoordinate formats 'l' as NMEA coordinate with 'digits' digits for the degrees, e.g.
+// ddmm.mmmm for latitudes, and the hemisphere 'positive' or 'negative'
+func nmeaCoordinate(l float64, digits int, positive, negative string) string {
+	hemisphere := positive
+	if l < 0 {
+		hemisphere = negative
+	}
+	v := math.Abs(l)
+	degrees := int(math.Floor(v))
+	minutes, carry := roundCarry((v-float64(degrees))*60, 4)
+	return fmt.Sprintf("%0*d%07.4f,%v", digits, degrees+carry, minutes, hemisphere)
+}
+
+// buildGGA builds a GGA sentence from 'p'. The fix quality is 0 without a fix, 2 with
+// differential corrections and 1 otherwise.
+func buildGGA(p data) string {
+	quality := "1"
+	switch {
+	case !p.fix:
+		quality = "0"
+	case p.Differential:
+		quality = "2"
+	}
+	// The altitude field is empty without a plausible altitude
+	var altitude string
+	if p.Altitude != nil {
+		altitude = fmt.Sprintf("%.1f", *p.Altitude)
+	}
+	return withChecksum(fmt.Sprintf("$GPGGA,%v,%v,%v,%v,%02d,%.1f,%v,M,,M,,",
+		p.Timestamp.UTC().Format("150405.00"),
+		nmeaCoordinate(p.Latitude, 2, "N", "S"),
+		nmeaCoordinate(p.Longitude, 3, "E", "W"),
+		quality, p.Satellites, p.HDOP, altitude))
+}
+
+// buildRMC builds an RMC sentence of NMEA 2.3 from 'p'
+func buildRMC(p data) string {
+	status := "V"
+	if p.Valid && p.fix {
+		status = rmcValid
+	}
+	mode := p.NavStatus
+	if mode == "" {
+		mode = "A"
+		if status != rmcValid {
+			mode = "N"
+		}
+	}
+	return withChecksum(fmt.Sprintf("$GPRMC,%v,%v,%v,%v,%.1f,%.1f,%v,,,%v",
+		p.Timestamp.UTC().Format("150405.00"), status,
+		nmeaCoordinate(p.Latitude, 2, "N", "S"),
+		nmeaCoordinate(p.Longitude, 3, "E", "W"),
+		p.Speed/knotsToKmh, p.Course,
+		p.Timestamp.UTC().Format("020106"), mode))
+}
+
+// writeNMEA writes GGA and RMC sentences regenerated from 'd' to --nmea-output with
+// --nmea-output-rate until the program ends. If writing fails, the port is reopened. Errors are
+// only logged once until writing succeeds again.
+func writeNMEA() {
+	var w io.WriteCloser
+	failing := false
+	for range time.Tick(time.Duration(float64(time.Second) / *nmeaOutputRate)) {
+		p := snapshot()
+		if p.Timestamp.IsZero() {
+			// Nothing received yet
+			continue
+		}
+		if w == nil {
+			port, err := serial.OpenPort(&serial.Config{Name: *nmeaOutput, Baud: *nmeaOutputBaudrate})
+			if err != nil {
+				if !failing {
+					log.Printf("Error while opening NMEA output %v, %v", *nmeaOutput, err)
+				}
+				failing = true
+				continue
+			}
+			w = port
+		}
+		_, err := io.WriteString(w, buildGGA(p)+buildRMC(p))
+		if err != nil {
+			if !failing {
+				log.Printf("Error while writing NMEA output %v, %v", *nmeaOutput, err)
+			}
+			failing = true
+			w.Close()
+			w = nil
+			continue
+		}
+		failing = false
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// noiseSample is a fix of a stationary receiver
+type noiseSample struct {
+	time      time.Time
+	latitude  float64
+	longitude float64
+	altitude  *float64
+}
+
+// noiseSamples collects the fixes of the last --noise-window while the receiver is stationary. They
+// are discarded as soon as it starts moving.
+type noiseSamples struct {
+	m       *sync.Mutex
+	samples []noiseSample
+}
+
+// noise collects the fixes of the stationary receiver for /noise
+var noise = noiseSamples{
+	m: &sync.Mutex{},
+}
+
+// spread is the deviation of the samples from their mean in meters
+type spread struct {
+	StdDev float64
+	P95    float64
+}
+
+// noiseStatistics is the JSON of the /noise endpoint
+type noiseStatistics struct {
+	Samples    int
+	Since      time.Time
+	Horizontal spread
+	Vertical   *spread `json:",omitempty"`
+}
+
+// Publish records the fix of 'p', or discards all samples if the receiver is moving
+func (n *noiseSamples) Publish(ctx context.Context, p data) error {
+	now := time.Now()
+	n.m.Lock()
+	defer n.m.Unlock()
+	if p.Moving {
+		n.samples = nil
+		return nil
+	}
+	if !p.fix {
+		return nil
+	}
+	n.samples = append(n.samples, noiseSample{time: now, latitude: p.Latitude, longitude: p.Longitude, altitude: p.Altitude})
+	i := 0
+	for i < len(n.samples) && now.Sub(n.samples[i].time) > *noiseWindow {
+		i++
+	}
+	n.samples = n.samples[i:]
+	return nil
+}
+
+// statistics returns the spread of the samples around their mean position and altitude, false if
+// there are not enough samples
+func (n *noiseSamples) statistics() (noiseStatistics, bool) {
+	n.m.Lock()
+	defer n.m.Unlock()
+	if len(n.samples) < 2 {
+		return noiseStatistics{}, false
+	}
+
+	var lat, lon, alt float64
+	altitudes := 0
+	for _, s := range n.samples {
+		lat += s.latitude
+		lon += s.longitude
+		if s.altitude != nil {
+			alt += *s.altitude
+			altitudes++
+		}
+	}
+	count := float64(len(n.samples))
+	lat, lon = lat/count, lon/count
+
+	horizontal := make([]float64, len(n.samples))
+	var vertical []float64
+	for i, s := range n.samples {
+		horizontal[i] = distance(lat, lon, s.latitude, s.longitude)
+		if s.altitude != nil {
+			vertical = append(vertical, math.Abs(*s.altitude-alt/float64(altitudes)))
+		}
+	}
+	stats := noiseStatistics{
+		Samples:    len(n.samples),
+		Since:      n.samples[0].time,
+		Horizontal: spreadOf(horizontal),
+	}
+	// The vertical spread needs at least two fixes with a plausible altitude
+	if altitudes >= 2 {
+		v := spreadOf(vertical)
+		stats.Vertical = &v
+	}
+	return stats, true
+}
+
+// spreadOf returns the standard deviation and the 95th percentile of the deviations 'dev'
+func spreadOf(dev []float64) spread {
+	sum := 0.0
+	for _, d := range dev {
+		sum += d * d
+	}
+	slices.Sort(dev)
+	return spread{
+		StdDev: math.Sqrt(sum / float64(len(dev))),
+		P95:    dev[int(math.Ceil(0.95*float64(len(dev))))-1],
+	}
+}
+
+// HTTP Handler to send the noise statistics of the stationary receiver as JSON, responds with 503
+// without enough samples
+func noiseHandler(w http.ResponseWriter, r *http.Request) {
+	stats, ok := noise.statistics()
+	if !ok {
+		httpError(w, "not enough stationary fixes", http.StatusServiceUnavailable)
+		return
+	}
+	js, err := json.Marshal(stats)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
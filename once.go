@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// oncePollInterval is the interval for checking for the first fix with --once
+const oncePollInterval = 100 * time.Millisecond
+
+// printFirstFix waits for the first fix, prints it as JSON to stdout and returns. It fails after
+// --once-timeout or if reading from the source stops before, as reported by 'errs'.
+func printFirstFix(errs chan error) error {
+	timeout := time.After(*onceTimeout)
+	ticker := time.NewTicker(oncePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-errs:
+			// The source may end right after the fix, e.g. with --replay
+			if printed, perr := printFix(); printed || perr != nil {
+				return perr
+			}
+			if err == nil {
+				return fmt.Errorf("no GPS fix before shutting down")
+			}
+			return fmt.Errorf("no GPS fix, %w", err)
+		case <-timeout:
+			return fmt.Errorf("no GPS fix within %v", *onceTimeout)
+		case <-ticker.C:
+			if printed, err := printFix(); printed || err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// printFix prints 'd' as JSON to stdout if there is a fix and returns whether it did. A GGA fix
+// alone is not printed before an RMC or ZDA gave the date.
+func printFix() (bool, error) {
+	p := snapshot()
+	if !p.fix || p.FromCache || !p.Valid && p.Timestamp.IsZero() {
+		return false, nil
+	}
+	p.Age = time.Since(p.update)
+	js, err := json.Marshal(inUnits(p))
+	if err != nil {
+		return false, err
+	}
+	fmt.Fprintln(os.Stdout, string(js))
+	return true, nil
+}
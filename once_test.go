@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestOnceRetriesExceeded(t *testing.T) {
+	old := d
+	defer func() { d = old }()
+	d = data{m: &sync.Mutex{}}
+	errs := make(chan error, 1)
+	errs <- fmt.Errorf("reading from /dev/ttyUSB0 stopped, %w", errRetriesExceeded)
+	err := printFirstFix(errs)
+	if !errors.Is(err, errRetriesExceeded) {
+		t.Errorf("printFirstFix returned %v, want it to wrap errRetriesExceeded", err)
+	}
+}
+
+func TestOnceNeedsDate(t *testing.T) {
+	old, oldStdout := d, os.Stdout
+	defer func() { d, os.Stdout = old, oldStdout }()
+	f, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	os.Stdout = f
+
+	// A GGA alone has a fix but no date
+	u := newUpdater()
+	err = u.process(sentence("GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = u.p
+	d.m = &sync.Mutex{}
+	if printed, err := printFix(); printed || err != nil {
+		t.Fatalf("printFix() = %v, %v without a date, want nothing printed", printed, err)
+	}
+	err = u.process(sentence("GPZDA,123520.00,23,03,1994,00,00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = u.p
+	d.m = &sync.Mutex{}
+	if printed, err := printFix(); !printed || err != nil {
+		t.Fatalf("printFix() = %v, %v with the date of ZDA, want it printed", printed, err)
+	}
+}
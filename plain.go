@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// plainHandler returns an HTTP Handler that sends the values returned by 'values' as comma
+// separated plain text. 'values' is called while 'd' is locked and returns nil if they are not
+// available. Without a GPS fix or values it responds with 503 instead of reporting zero values.
+// With 'cached' the data restored with FromCache is served as well until the first fix.
+func plainHandler(values func() []float64, cached bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		precision, err := queryPrecision(r)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		d.m.Lock()
+		fix := d.fix || cached && d.FromCache
+		v := values()
+		d.m.Unlock()
+		if !fix {
+			httpError(w, "no GPS fix", http.StatusServiceUnavailable)
+			return
+		}
+		if v == nil {
+			httpError(w, "not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		s := make([]string, len(v))
+		for i := range v {
+			s[i] = strconv.FormatFloat(v[i], 'f', precision, 64)
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Join(s, ",")))
+	}
+}
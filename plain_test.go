@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestPlainHandlerFromCache(t *testing.T) {
+	old := d
+	defer func() { d = old }()
+	d = data{m: &sync.Mutex{}, Latitude: 52.5163, Longitude: 13.3777, FromCache: true}
+	latlon := func() []float64 { return []float64{d.Latitude, d.Longitude} }
+	speed := func() []float64 { return []float64{d.Speed} }
+
+	tests := []struct {
+		handler http.HandlerFunc
+		code    int
+		body    string
+	}{
+		{plainHandler(latlon, true), http.StatusOK, "52.5163,13.3777"},
+		{plainHandler(speed, false), http.StatusServiceUnavailable, ""},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.handler(w, httptest.NewRequest(http.MethodGet, "/?precision=4", nil))
+		if w.Code != tt.code || tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%v: responded %v %q, want %v %q", i, w.Code, w.Body, tt.code, tt.body)
+		}
+	}
+
+	// After the fix is lost the cached position is not served anymore
+	d.FromCache = false
+	w := httptest.NewRecorder()
+	plainHandler(latlon, true)(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("responded %v without a fix, want 503", w.Code)
+	}
+}
+
+func TestSpeedDefaultUnit(t *testing.T) {
+	old, oldUnits := d, *outputUnits
+	defer func() { d, *outputUnits = old, oldUnits }()
+	d = data{m: &sync.Mutex{}, fix: true, Speed: 1.609344}
+	tests := []struct {
+		units, query, want string
+	}{
+		{unitsMetric, "?precision=1", "1.6"},
+		{unitsImperial, "?precision=1", "1.0"},
+		{unitsImperial, "?unit=kmh&precision=1", "1.6"},
+	}
+	for _, tt := range tests {
+		*outputUnits = tt.units
+		w := httptest.NewRecorder()
+		speedHandler(w, httptest.NewRequest(http.MethodGet, "/speed"+tt.query, nil))
+		if w.Body.String() != tt.want {
+			t.Errorf("/speed%v with %v is %q, want %v", tt.query, tt.units, w.Body, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// maxPrecision is the highest number of decimal places of ?precision, float64 has no more
+// meaningful digits on degrees
+const maxPrecision = 10
+
+// queryPrecision returns the decimal places of the query parameter precision, clamped to
+// 0..maxPrecision. It returns -1 if the parameter is not set.
+func queryPrecision(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("precision")
+	if v == "" {
+		return -1, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid precision %q", v)
+	}
+	return min(max(n, 0), maxPrecision), nil
+}
+
+// roundTo rounds 'v' to 'decimals' decimal places
+func roundTo(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
+
+// applyPrecision rounds the position of 'p' to 'decimals' decimal places, which overrides
+// --gps-precision and --dms-precision for the formatted coordinates
+func applyPrecision(p *data, decimals int) {
+	p.LatitudeGPS = formatGPS(p.Latitude, decimals)
+	p.LongitudeGPS = formatGPS(p.Longitude, decimals)
+	p.LatitudeDMS = formatDMS(p.Latitude, decimals)
+	p.LongitudeDMS = formatDMS(p.Longitude, decimals)
+	p.Latitude = roundTo(p.Latitude, decimals)
+	p.Longitude = roundTo(p.Longitude, decimals)
+	// 'p' shares the pointers with 'd', so they are replaced instead of changed
+	if p.Altitude != nil {
+		a := roundTo(*p.Altitude, decimals)
+		p.Altitude = &a
+	}
+	if p.AltitudeRelative != nil {
+		a := roundTo(*p.AltitudeRelative, decimals)
+		p.AltitudeRelative = &a
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// qualityMinutes is the number of minutes the link quality history covers
+const qualityMinutes = 60
+
+// qualityBucket counts the valid and invalid sentences of one minute
+type qualityBucket struct {
+	Minute  time.Time
+	Valid   int64
+	Invalid int64
+}
+
+// quality is the link quality history as a ring of per minute buckets, which bounds the memory
+type quality struct {
+	m       *sync.Mutex
+	buckets [qualityMinutes]qualityBucket
+}
+
+// linkQuality is the link quality history of the source
+var linkQuality = quality{
+	m: &sync.Mutex{},
+}
+
+// add counts a valid or invalid sentence at 'now'
+func (q *quality) add(now time.Time, valid bool) {
+	q.m.Lock()
+	defer q.m.Unlock()
+	minute := now.UTC().Truncate(time.Minute)
+	b := &q.buckets[minute.Unix()/60%qualityMinutes]
+	if !b.Minute.Equal(minute) {
+		*b = qualityBucket{Minute: minute}
+	}
+	if valid {
+		b.Valid++
+	} else {
+		b.Invalid++
+	}
+}
+
+// history returns the buckets of the last qualityMinutes up to 'now', oldest first. Minutes
+// without sentences are included with zero counts.
+func (q *quality) history(now time.Time) []qualityBucket {
+	q.m.Lock()
+	defer q.m.Unlock()
+	h := make([]qualityBucket, qualityMinutes)
+	current := now.UTC().Truncate(time.Minute)
+	for i := range h {
+		minute := current.Add(time.Duration(i-qualityMinutes+1) * time.Minute)
+		b := q.buckets[minute.Unix()/60%qualityMinutes]
+		if !b.Minute.Equal(minute) {
+			b = qualityBucket{Minute: minute}
+		}
+		h[i] = b
+	}
+	return h
+}
+
+// HTTP Handler to send the link quality history as JSON
+func qualityHandler(w http.ResponseWriter, r *http.Request) {
+	js, err := json.Marshal(linkQuality.history(time.Now()))
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
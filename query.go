@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxQueryTimeout is the longest ?timeout of /query
+const maxQueryTimeout = time.Minute
+
+// errNotWritable is returned by query if the source can't be written to, e.g. stdin or a replay
+var errNotWritable = errors.New("the source does not accept commands")
+
+// pendingQuery is a query waiting for the first sentence starting with 'response'
+type pendingQuery struct {
+	response string
+	answer   chan string
+}
+
+// receiverQueries sends commands to the receiver and waits for their responses among the read
+// sentences, e.g. of PUBX polls or PMTK605
+type receiverQueries struct {
+	m       *sync.Mutex
+	w       io.Writer // the connected receiver, nil if it can't be written to
+	pending map[*pendingQuery]bool
+	// writing serializes the commands, it is held while a write stalls
+	writing *sync.Mutex
+}
+
+// queries are the queries to the receiver of the source
+var queries = receiverQueries{
+	m:       &sync.Mutex{},
+	pending: map[*pendingQuery]bool{},
+	writing: &sync.Mutex{},
+}
+
+// connect sets the receiver the commands are sent to, nil while disconnected
+func (q *receiverQueries) connect(w io.Writer) {
+	q.m.Lock()
+	defer q.m.Unlock()
+	q.w = w
+}
+
+// responds returns true if the raw 'sentence' starts with 'response', the address field and
+// optionally the first data fields without '$', e.g. "PUBX,00" or "PMTK705"
+func responds(sentence, response string) bool {
+	if len(sentence) < 1 || !strings.HasPrefix(sentence[1:], response) {
+		return false
+	}
+	rest := sentence[1+len(response):]
+	return rest == "" || rest[0] == ',' || rest[0] == '*'
+}
+
+// match hands the raw 'sentence' to all pending queries waiting for it
+func (q *receiverQueries) match(sentence string) {
+	q.m.Lock()
+	defer q.m.Unlock()
+	for p := range q.pending {
+		if responds(sentence, p.response) {
+			p.answer <- sentence
+			delete(q.pending, p)
+		}
+	}
+}
+
+// query sends the command 'cmd' to the receiver and waits for the first sentence starting with
+// 'response' until 'ctx' is done. The checksum of 'cmd' is appended if it is missing.
+func (q *receiverQueries) query(ctx context.Context, cmd, response string) (string, error) {
+	p := &pendingQuery{response: response, answer: make(chan string, 1)}
+	q.m.Lock()
+	if q.w == nil {
+		q.m.Unlock()
+		return "", errNotWritable
+	}
+	// The query waits before it is sent, so a fast response is not missed
+	q.pending[p] = true
+	w := q.w
+	q.m.Unlock()
+
+	err := q.write(ctx, w, cmd)
+	if err != nil {
+		q.m.Lock()
+		delete(q.pending, p)
+		q.m.Unlock()
+		return "", fmt.Errorf("can't send %v, %w", cmd, err)
+	}
+
+	select {
+	case s := <-p.answer:
+		return s, nil
+	case <-ctx.Done():
+		q.m.Lock()
+		delete(q.pending, p)
+		q.m.Unlock()
+		return "", fmt.Errorf("no %v response to %v, %w", response, cmd, ctx.Err())
+	}
+}
+
+// write sends 'cmd' to 'w' until 'ctx' is done. It doesn't hold the lock of match, so a write
+// stalled by flow control or a full TCP window never stalls the processing of the sentences.
+// Writers with a deadline, like TCP connections, give up at the deadline of 'ctx', others keep
+// trying in the background.
+func (q *receiverQueries) write(ctx context.Context, w io.Writer, cmd string) error {
+	done := make(chan error, 1)
+	go func() {
+		q.writing.Lock()
+		defer q.writing.Unlock()
+		if ctx.Err() != nil {
+			done <- ctx.Err()
+			return
+		}
+		if d, ok := w.(interface{ SetWriteDeadline(time.Time) error }); ok {
+			deadline, _ := ctx.Deadline()
+			d.SetWriteDeadline(deadline)
+			defer d.SetWriteDeadline(time.Time{})
+		}
+		_, err := io.WriteString(w, withChecksum(cmd))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// queryResponse is the JSON of /query
+type queryResponse struct {
+	Command  string
+	Response string
+}
+
+// HTTP Handler to send ?command= to the receiver and respond with the first sentence starting with
+// ?response=, e.g. /query?command=$PUBX,00&response=PUBX,00. It waits --query-timeout or ?timeout.
+func queryHandler(w http.ResponseWriter, r *http.Request) {
+	cmd := r.URL.Query().Get("command")
+	response := strings.TrimPrefix(r.URL.Query().Get("response"), "$")
+	if !strings.HasPrefix(cmd, "$") || response == "" {
+		httpError(w, "command must start with '$' and response must be set", http.StatusBadRequest)
+		return
+	}
+	timeout := *queryTimeout
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		var err error
+		timeout, err = time.ParseDuration(v)
+		if err != nil || timeout <= 0 || timeout > maxQueryTimeout {
+			httpError(w, fmt.Sprintf("invalid timeout %q, must be positive and at most %v", v, maxQueryTimeout), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	s, err := queries.query(ctx, cmd, response)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		httpError(w, err.Error(), http.StatusGatewayTimeout)
+		return
+	case err != nil:
+		httpError(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	js, err := json.Marshal(queryResponse{Command: strings.TrimSpace(withChecksum(cmd)), Response: s})
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+// minReadBuffer is the smallest buffer size of bufio
+const minReadBuffer = 16
+
+// readLines reads the sentences from 'in' into 'lines' until reading fails permanently, then
+// closes 'lines'. For live sources it never blocks on a full queue but drops the sentence, so the
+// source is drained even while the processing stalls and the OS buffer of the serial port doesn't
+// overrun. Files and stdin wait for the queue instead, as they would be read faster than processed.
+func readLines(in input, lines chan<- string) error {
+	live := liveSource()
+	defer close(lines)
+	// Use a buffered reader. We do not want to read byte-wise and look for newlines.
+	reader := bufio.NewReaderSize(in, *readBuffer)
+	timeouts := 0
+	for {
+		// Read line
+		sentence, err := reader.ReadString('\n')
+		if isTimeout(in, err) {
+			timeouts++
+			if *maxTimeouts > 0 && timeouts >= *maxTimeouts {
+				return errNoData
+			}
+			if *verbose {
+				log.Printf("Timeout while reading from %v\n", sourceName())
+			}
+			continue
+		}
+		// A last line without newline is still a sentence
+		if err == io.EOF {
+			if strings.TrimSpace(sentence) != "" {
+				queue(lines, sentence, live)
+			}
+			return err
+		}
+		if errors.Is(err, errHangup) {
+			readErrors.Add(1)
+			return err
+		}
+		if err != nil {
+			readErrors.Add(1)
+			log.Printf("Error while reading from %v, %v", sourceName(), err)
+			// Do not busy-loop on a device that keeps failing
+			time.Sleep(retryDelay)
+			continue
+		}
+		timeouts = 0
+
+		queue(lines, sentence, live)
+	}
+}
+
+// queue queues the sentences of the read 'line' in 'lines'. If the queue is full, they are dropped
+// for a 'live' source, otherwise it waits.
+func queue(lines chan<- string, line string, live bool) {
+	// Sources that don't frame cleanly glue several sentences into one line
+	for _, sentence := range splitSentences(line) {
+		if degrading() {
+			var ok bool
+			if sentence, ok = degrader.apply(time.Now(), sentence); !ok {
+				continue
+			}
+		}
+		// Counted before the send, otherwise the worker may take it first and the count goes negative
+		queuedSentences.Add(1)
+		if !live {
+			lines <- sentence
+			continue
+		}
+		select {
+		case lines <- sentence:
+		default:
+			queuedSentences.Add(-1)
+			droppedSentences.Add(1)
+		}
+	}
+}
+
+// splitSentences splits the 'line' read from the source into the sentences it contains. Each
+// sentence starts with '$' or '!', which can't occur within a sentence, and \r\n is stripped. A
+// line without any start marker is kept as is, so it is reported as unparsable.
+func splitSentences(line string) []string {
+	line = strings.TrimRight(line, "\r\n")
+	var sentences []string
+	for {
+		i := strings.IndexAny(line[min(1, len(line)):], "$!")
+		if i < 0 {
+			break
+		}
+		sentences = appendSentence(sentences, line[:i+1])
+		line = line[i+1:]
+	}
+	return appendSentence(sentences, line)
+}
+
+// appendSentence appends 'sentence' without surrounding whitespace and line breaks, unless empty
+func appendSentence(sentences []string, sentence string) []string {
+	sentence = strings.TrimSpace(sentence)
+	if sentence == "" {
+		return sentences
+	}
+	return append(sentences, sentence)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitSentences(t *testing.T) {
+	rmc := sentence("GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W")
+	gga := sentence("GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,")
+	ais := "!AIVDM,1,1,,A,13aEOK?P00PD2wVMdLDRhgvL289?,0*26"
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{"single", rmc + "\r\n", []string{rmc}},
+		{"glued", rmc + gga + "\r\n", []string{rmc, gga}},
+		{"glued with AIS", rmc + ais + gga + "\n", []string{rmc, ais, gga}},
+		{"carriage returns only", rmc + "\r" + gga + "\r", []string{rmc, gga}},
+		{"garbage before", "xx" + rmc, []string{"xx", rmc}},
+		{"no start marker", "garbage\r\n", []string{"garbage"}},
+		{"empty", "\r\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitSentences(tt.line); !slices.Equal(got, tt.want) {
+				t.Errorf("splitSentences(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadLinesGlued(t *testing.T) {
+	rmc := sentence("GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W")
+	gga := sentence("GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,")
+	zda := sentence("GPZDA,123519.00,23,03,1994,00,00")
+	in := input{ReadCloser: io.NopCloser(strings.NewReader(rmc + gga + "\r\n" + zda + "\r" + rmc + "\r\n"))}
+	lines := make(chan string, 10)
+	err := readLines(in, lines)
+	if err != io.EOF {
+		t.Errorf("readLines returned %v, want EOF", err)
+	}
+
+	var got []string
+	u := newUpdater()
+	for s := range lines {
+		queuedSentences.Add(-1)
+		got = append(got, s)
+		if err := u.process(s); err != nil {
+			t.Errorf("process(%q) failed, %v", s, err)
+		}
+	}
+	if want := []string{rmc, gga, zda, rmc}; !slices.Equal(got, want) {
+		t.Errorf("read %q, want %q", got, want)
+	}
+	if u.p.Satellites != 8 || u.zdaYear != 1994 || !u.p.Valid {
+		t.Errorf("sentences not parsed, satellites %v, ZDA year %v, valid %v", u.p.Satellites, u.zdaYear, u.p.Valid)
+	}
+}
+
+func TestQueuedSentencesNeverNegative(t *testing.T) {
+	var lines []string
+	for range 1000 {
+		lines = append(lines, sentence("GPZDA,123519.00,23,03,1994,00,00"))
+	}
+	in := input{ReadCloser: io.NopCloser(strings.NewReader(strings.Join(lines, "\r\n")))}
+	ch := make(chan string, 1)
+	done := make(chan struct{})
+	start := queuedSentences.Load()
+	go func() {
+		defer close(done)
+		for range ch {
+			if n := queuedSentences.Add(-1); n < start {
+				t.Errorf("%v queued sentences, want at least %v", n, start)
+			}
+		}
+	}()
+	readLines(in, ch)
+	<-done
+	if n := queuedSentences.Load(); n != start {
+		t.Errorf("%v queued sentences after draining, want %v", n, start)
+	}
+}
+
+func TestReadLinesStdinWaits(t *testing.T) {
+	old := *source
+	*source = sourceStdin
+	defer func() { *source = old }()
+	const n = 1000
+	var lines []string
+	for range n {
+		lines = append(lines, sentence("GPZDA,123519.00,23,03,1994,00,00"))
+	}
+	in := input{ReadCloser: io.NopCloser(strings.NewReader(strings.Join(lines, "\r\n") + "\r\n"))}
+	ch := make(chan string, 1)
+	received := make(chan int)
+	go func() {
+		count := 0
+		for range ch {
+			queuedSentences.Add(-1)
+			count++
+		}
+		received <- count
+	}()
+	dropped := droppedSentences.Load()
+	readLines(in, ch)
+	if got := <-received; got != n {
+		t.Errorf("received %v sentences, want %v", got, n)
+	}
+	if got := droppedSentences.Load() - dropped; got != 0 {
+		t.Errorf("dropped %v sentences of stdin", got)
+	}
+}
+
+func TestReadLinesWithoutTrailingNewline(t *testing.T) {
+	rmc := sentence("GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W")
+	gga := sentence("GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,")
+	in := input{ReadCloser: io.NopCloser(strings.NewReader(rmc + "\r\n" + gga))}
+	lines := make(chan string, 10)
+	err := readLines(in, lines)
+	if err != io.EOF {
+		t.Errorf("readLines returned %v, want EOF", err)
+	}
+	var got []string
+	for s := range lines {
+		queuedSentences.Add(-1)
+		got = append(got, s)
+	}
+	if want := []string{rmc, gga}; !slices.Equal(got, want) {
+		t.Errorf("read %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// readiness is the JSON of the /ready endpoint
+type readiness struct {
+	Ready  bool
+	Reason string `json:",omitempty"`
+}
+
+// checkReady returns whether the fix of 'p' is good enough to serve traffic according to
+// --min-ready-sats and --min-ready-fix, and the reason if not
+func checkReady(p data) readiness {
+	switch {
+	case !p.fix:
+		return readiness{Reason: "no GPS fix"}
+	case !p.Valid:
+		return readiness{Reason: "RMC status void"}
+	case fixTypeRank[p.FixType] < fixTypeRank[*minReadyFix]:
+		return readiness{Reason: fmt.Sprintf("fix type %q below %v", p.FixType, *minReadyFix)}
+	case p.Satellites < int64(*minReadySats):
+		return readiness{Reason: fmt.Sprintf("%v satellites below %v", p.Satellites, *minReadySats)}
+	}
+	return readiness{Ready: true}
+}
+
+// HTTP Handler to report the readiness, responds with 503 until a trustworthy fix is achieved
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	ready := checkReady(snapshot())
+	js, err := json.Marshal(ready)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ready.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(js)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Antenna states of receiverInfo
+const (
+	antennaOK       = "ok"       // active antenna connected
+	antennaShort    = "short"    // antenna short circuit
+	antennaOpen     = "open"     // antenna disconnected
+	antennaInternal = "internal" // internal patch antenna used
+	antennaUnknown  = "unknown"  // the receiver can't tell
+)
+
+// uBloxAntenna are the antenna states of the ANTSTATUS text of u-blox receivers
+var uBloxAntenna = map[string]string{
+	"OK":       antennaOK,
+	"SHORT":    antennaShort,
+	"OPEN":     antennaOpen,
+	"DONTKNOW": antennaUnknown,
+}
+
+// mtkAntenna are the antenna states of PGTOP,11 of MediaTek receivers
+var mtkAntenna = map[string]string{
+	"1": antennaShort,
+	"2": antennaInternal,
+	"3": antennaOK,
+}
+
+// receiverInfo is the metadata the receiver reported about itself, fields stay empty if it
+// reports nothing
+type receiverInfo struct {
+	m              *sync.Mutex
+	Vendor         string    `json:",omitempty"`
+	Module         string    `json:",omitempty"`
+	Hardware       string    `json:",omitempty"`
+	Firmware       string    `json:",omitempty"`
+	Protocol       string    `json:",omitempty"`
+	Antenna        string    `json:",omitempty"` // "ok", "short", "open", "internal" or "unknown"
+	AntennaUpdated time.Time `json:",omitzero"`
+}
+
+// receiver is the metadata of the receiver on /receiver
+var receiver = receiverInfo{
+	m: &sync.Mutex{},
+}
+
+// setAntenna sets the antenna state, a change is reported as event
+func (r *receiverInfo) setAntenna(state string) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if state != r.Antenna {
+		event(eventAntenna, "antenna %v", state)
+	}
+	r.Antenna = state
+	r.AntennaUpdated = time.Now()
+}
+
+// processTXT collects the receiver metadata from a TXT sentence of any talker. u-blox receivers
+// send their version and antenna status as text on start and on changes.
+func (u *updater) processTXT(sentence string) error {
+	_, _, fields, err := splitSentence(sentence)
+	if err != nil {
+		return err
+	}
+	if len(fields) < 4 {
+		return fmt.Errorf("TXT with %v fields", len(fields))
+	}
+
+	text := fields[3]
+	if state, ok := strings.CutPrefix(text, "ANTSTATUS="); ok {
+		if s, ok := uBloxAntenna[state]; ok {
+			receiver.setAntenna(s)
+		}
+		return nil
+	}
+	receiver.m.Lock()
+	defer receiver.m.Unlock()
+	switch {
+	case strings.HasPrefix(text, "u-blox"):
+		receiver.Vendor = "u-blox"
+	case strings.HasPrefix(text, "HW "):
+		receiver.Hardware = strings.TrimPrefix(text, "HW ")
+	case strings.HasPrefix(text, "FWVER="):
+		receiver.Firmware = strings.TrimPrefix(text, "FWVER=")
+	case strings.HasPrefix(text, "ROM CORE ") && receiver.Firmware == "":
+		receiver.Firmware = text
+	case strings.HasPrefix(text, "PROTVER="):
+		receiver.Protocol = strings.TrimPrefix(text, "PROTVER=")
+	case strings.HasPrefix(text, "MOD="):
+		receiver.Module = strings.TrimPrefix(text, "MOD=")
+	}
+	return nil
+}
+
+// processProprietary collects the receiver metadata from the proprietary sentences of MediaTek
+// receivers. It returns false for other sentences.
+func (u *updater) processProprietary(sentence string) (bool, error) {
+	fields, err := sentenceFields(sentence)
+	if err != nil {
+		return false, nil
+	}
+	switch fields[0] {
+	case "PMTK705":
+		// Response to PMTK605, the firmware release
+		if len(fields) < 2 {
+			return true, fmt.Errorf("PMTK705 with %v fields", len(fields)-1)
+		}
+		receiver.m.Lock()
+		receiver.Vendor = "MediaTek"
+		receiver.Firmware = fields[1]
+		receiver.m.Unlock()
+		return true, nil
+	case "PGTOP":
+		// Antenna status, sent every second by default
+		if len(fields) < 3 || fields[1] != "11" {
+			return true, fmt.Errorf("unsupported PGTOP %v", sentence)
+		}
+		if s, ok := mtkAntenna[fields[2]]; ok {
+			receiver.setAntenna(s)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// receiverHandler responds with the metadata of the receiver, empty if it reported nothing
+func receiverHandler(w http.ResponseWriter, r *http.Request) {
+	receiver.m.Lock()
+	js, err := json.Marshal(receiver)
+	receiver.m.Unlock()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
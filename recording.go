@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// recorder records the raw NMEA sentences to --record, nil if unset or with --record-ring
+	recorder *rotatingFile
+	// ring records the last raw NMEA sentences to --record with --record-ring, nil otherwise
+	ring *sentenceRing
+	// recordFailing is true while writing to the recording fails, to log the error only once
+	recordFailing bool
+)
+
+// segment is the JSON of a recording segment on /recordings
+type segment struct {
+	Name     string
+	Size     int64
+	Modified time.Time
+}
+
+// record writes the raw NMEA 'sentence' to the recording. Errors do not interrupt the GPS
+// updates, they are only logged.
+func record(sentence string) {
+	if ring != nil {
+		ring.write(sentence)
+	}
+	if recorder == nil {
+		return
+	}
+	_, err := io.WriteString(recorder, sentence+"\r\n")
+	if err != nil && !recordFailing {
+		log.Printf("Error while writing to the recording %v, %v", *recordFile, err)
+	}
+	recordFailing = err != nil
+}
+
+// segments returns the current recording and the rotated ones that exist, newest first. The ring
+// of --record-ring is the only segment.
+func segments() []segment {
+	var l []segment
+	if ring != nil {
+		fi, err := ring.f.Stat()
+		if err == nil {
+			l = append(l, segment{Name: fi.Name(), Size: fi.Size(), Modified: fi.ModTime()})
+		}
+		return l
+	}
+	for i := 0; i <= *recordKeep; i++ {
+		name := *recordFile
+		if i > 0 {
+			name = fmt.Sprintf("%v.%v", name, i)
+		}
+		fi, err := os.Stat(name)
+		if err != nil {
+			continue
+		}
+		l = append(l, segment{Name: filepath.Base(name), Size: fi.Size(), Modified: fi.ModTime()})
+	}
+	return l
+}
+
+// HTTP Handler to list the recording segments as JSON
+func recordingsHandler(w http.ResponseWriter, r *http.Request) {
+	if recorder == nil && ring == nil {
+		httpError(w, "recording is disabled", http.StatusNotFound)
+		return
+	}
+	js, err := json.Marshal(segments())
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
+
+// HTTP Handler to download the recording segment ?segment=<name>, by default the current one.
+// With ?gzip=true it is compressed on the fly. Only the names of /recordings are accepted, so the
+// segment can't point anywhere else. The ring of --record-ring is served as text, oldest first.
+// While fuzzing it responds with 403, as the raw sentences have the exact coordinates.
+func recordingHandler(w http.ResponseWriter, r *http.Request) {
+	if recorder == nil && ring == nil {
+		httpError(w, "recording is disabled", http.StatusNotFound)
+		return
+	}
+	if fuzzing() {
+		httpError(w, "the recording is not served while the position is fuzzed", http.StatusForbidden)
+		return
+	}
+	name := r.URL.Query().Get("segment")
+	if name == "" {
+		name = filepath.Base(*recordFile)
+	}
+	var found bool
+	for _, s := range segments() {
+		found = found || s.Name == name
+	}
+	if !found {
+		httpError(w, fmt.Sprintf("unknown segment %v", name), http.StatusNotFound)
+		return
+	}
+
+	var f io.Reader
+	if ring != nil {
+		f = bytes.NewReader(ring.text())
+	} else {
+		file, err := os.Open(filepath.Join(filepath.Dir(*recordFile), name))
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+		f = file
+	}
+
+	if r.URL.Query().Get("gzip") != "true" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		io.Copy(w, f)
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".gz"))
+	gz := gzip.NewWriter(w)
+	io.Copy(gz, f)
+	gz.Close()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordingRefusedWhileFuzzing(t *testing.T) {
+	oldRecorder, oldFile, oldGrid := recorder, *recordFile, *fuzzGrid
+	defer func() { recorder, *recordFile, *fuzzGrid = oldRecorder, oldFile, oldGrid }()
+	*recordFile = filepath.Join(t.TempDir(), "nmea.log")
+	var err error
+	recorder, err = openRotatingFile(*recordFile, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recorder.f.Close()
+	record(sentence("GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,"))
+
+	w := httptest.NewRecorder()
+	recordingHandler(w, httptest.NewRequest(http.MethodGet, "/recording", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("responded %v without fuzzing, want 200", w.Code)
+	}
+
+	*fuzzGrid = 100
+	w = httptest.NewRecorder()
+	recordingHandler(w, httptest.NewRequest(http.MethodGet, "/recording", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("responded %v while fuzzing, want 403", w.Code)
+	}
+}
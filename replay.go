@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"time"
+)
+
+// replay reads a recorded NMEA log line by line with --replay-interval between the lines. Gzip
+// compressed logs are decompressed transparently. With --replay-loop it starts over at the end.
+type replay struct {
+	f       *os.File
+	r       *bufio.Reader
+	pending []byte
+}
+
+// openReplay opens the recorded NMEA log --replay
+func openReplay() (input, error) {
+	r := &replay{}
+	err := r.open()
+	if err != nil {
+		return input{}, err
+	}
+	return input{ReadCloser: r}, nil
+}
+
+// open opens the log and detects gzip compression by its magic bytes
+func (r *replay) open() error {
+	f, err := os.Open(*replayFile)
+	if err != nil {
+		return err
+	}
+	r.f = f
+	r.r = bufio.NewReader(f)
+
+	magic, _ := r.r.Peek(2)
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		z, err := gzip.NewReader(r.r)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		r.r = bufio.NewReader(z)
+	}
+	return nil
+}
+
+// Read returns the next line of the log after --replay-interval
+func (r *replay) Read(b []byte) (int, error) {
+	for len(r.pending) == 0 {
+		time.Sleep(*replayInterval)
+		line, err := r.r.ReadBytes('\n')
+		r.pending = line
+		if err == io.EOF && *replayLoop {
+			r.f.Close()
+			err = r.open()
+		}
+		if err != nil && len(r.pending) == 0 {
+			return 0, err
+		}
+	}
+	n := copy(b, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Close closes the log
+func (r *replay) Close() error {
+	return r.f.Close()
+}
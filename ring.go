@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Layout of the ring file of --record-ring. The header is followed by fixed size slots of one
+// sentence each: sequence number, CRC-32 of the rest of the slot, length and the sentence.
+const (
+	ringMagic      = "NMEARNG1"
+	ringHeaderSize = 16
+	ringSlotSize   = 128
+	ringSlotHeader = 14
+	ringMaxLength  = ringSlotSize - ringSlotHeader // longer than the 82 characters of NMEA 0183
+)
+
+// sentenceRing records the last sentences to a memory mapped file. A write is just a copy into
+// the page cache, so it survives a crash of the process without a system call per sentence, and
+// the file is flushed to the storage every --record-sync to survive a power loss as well.
+type sentenceRing struct {
+	m     *sync.Mutex
+	f     *os.File
+	b     []byte // the mapped file, nil once closed
+	slots int
+	next  int    // slot of the next sentence
+	seq   uint64 // sequence number of the next sentence
+	done  chan struct{}
+}
+
+// openRing opens or creates the ring file 'name' with 'slots' sentences. An existing ring of the
+// same size is recovered, its sentences are kept until they are overwritten.
+func openRing(name string, slots int) (*sentenceRing, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	size := ringHeaderSize + slots*ringSlotSize
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.Size() != int64(size) {
+		if fi.Size() > 0 {
+			log.Printf("Warning: discarding ring %v of %v bytes, %v sentences need %v bytes", name, fi.Size(), slots, size)
+		}
+		err = f.Truncate(0)
+		if err == nil {
+			err = f.Truncate(int64(size))
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	b, err := mapFile(f, size)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r := &sentenceRing{m: &sync.Mutex{}, f: f, b: b, slots: slots, seq: 1, done: make(chan struct{})}
+	if string(b[:len(ringMagic)]) != ringMagic || int(binary.LittleEndian.Uint32(b[8:])) != slots {
+		clear(b)
+		copy(b, ringMagic)
+		binary.LittleEndian.PutUint32(b[8:], uint32(slots))
+		return r, nil
+	}
+	for i := range slots {
+		if seq, _, ok := r.slot(i); ok && seq >= r.seq {
+			r.seq = seq + 1
+			r.next = (i + 1) % slots
+		}
+	}
+	return r, nil
+}
+
+// slot returns the sequence number and sentence of slot 'i', false if it is empty or torn
+func (r *sentenceRing) slot(i int) (uint64, string, bool) {
+	s := r.b[ringHeaderSize+i*ringSlotSize:][:ringSlotSize]
+	seq := binary.LittleEndian.Uint64(s)
+	n := int(binary.LittleEndian.Uint16(s[12:]))
+	if seq == 0 || n > ringMaxLength {
+		return 0, "", false
+	}
+	if crc32.ChecksumIEEE(slices.Concat(s[:8], s[12:ringSlotHeader+n])) != binary.LittleEndian.Uint32(s[8:]) {
+		return 0, "", false
+	}
+	return seq, string(s[ringSlotHeader : ringSlotHeader+n]), true
+}
+
+// write records 'sentence' in the next slot, overwriting the oldest one. Sentences that don't
+// fit into a slot are skipped.
+func (r *sentenceRing) write(sentence string) {
+	if len(sentence) > ringMaxLength {
+		return
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	if r.b == nil {
+		return
+	}
+	s := r.b[ringHeaderSize+r.next*ringSlotSize:][:ringSlotSize]
+	binary.LittleEndian.PutUint64(s, r.seq)
+	binary.LittleEndian.PutUint16(s[12:], uint16(len(sentence)))
+	copy(s[ringSlotHeader:], sentence)
+	binary.LittleEndian.PutUint32(s[8:], crc32.ChecksumIEEE(slices.Concat(s[:8], s[12:ringSlotHeader+len(sentence)])))
+	r.seq++
+	r.next = (r.next + 1) % r.slots
+}
+
+// sentences returns the recorded sentences, oldest first
+func (r *sentenceRing) sentences() []string {
+	r.m.Lock()
+	defer r.m.Unlock()
+	var l []string
+	if r.b == nil {
+		return l
+	}
+	for i := range r.slots {
+		if _, sentence, ok := r.slot((r.next + i) % r.slots); ok {
+			l = append(l, sentence)
+		}
+	}
+	return l
+}
+
+// text returns the recorded sentences like a recording, one per line
+func (r *sentenceRing) text() []byte {
+	var b bytes.Buffer
+	for _, sentence := range r.sentences() {
+		b.WriteString(sentence + "\r\n")
+	}
+	return b.Bytes()
+}
+
+// sync flushes the mapped file to the storage every 'interval' until close
+func (r *sentenceRing) sync(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		}
+		r.m.Lock()
+		err := r.flush()
+		r.m.Unlock()
+		if err != nil {
+			log.Printf("Error while flushing the recording %v, %v", r.f.Name(), err)
+		}
+	}
+}
+
+// flush writes the mapped file to the storage, r.m must be held
+func (r *sentenceRing) flush() error {
+	if r.b == nil {
+		return nil
+	}
+	return syncMap(r.b)
+}
+
+// close flushes the ring a last time and closes it, later sentences are not recorded
+func (r *sentenceRing) close() error {
+	close(r.done)
+	r.m.Lock()
+	defer r.m.Unlock()
+	err := r.flush()
+	if err != nil {
+		log.Printf("Error while flushing the recording %v, %v", r.f.Name(), err)
+	}
+	if r.b != nil {
+		unmapFile(r.b)
+		r.b = nil
+	}
+	return r.f.Close()
+}
+
+// replayRing processes the sentences recovered from the ring with --record-ring-replay before
+// the source is read, so the last known state is restored. Like the state file the result is
+// flagged with FromCache and keeps the timestamp of the GPS data. It is neither recorded nor
+// published to the outputs, and the replayed fixes don't change /stats, /events, /anchor,
+// /receiver or the fix quality alarm.
+func replayRing(r *sentenceRing) {
+	defer saveGlobals()()
+	u := newUpdater()
+	l := r.sentences()
+	for _, sentence := range l {
+		err := u.process(sentence)
+		if err != nil && *verbose {
+			log.Printf("Error while replaying '%v', %v", sentence, err)
+		}
+	}
+	p := u.p
+	p.fix = false
+	p.update = p.Timestamp
+	p.Updated = fieldUpdates{Position: p.Timestamp, Altitude: p.Timestamp, Satellites: p.Timestamp}
+	p.FromCache = true
+	store(p)
+	log.Printf("Replayed %v sentences recovered from %v", len(l), r.f.Name())
+}
+
+// saveGlobals saves the state that processing sentences changes besides the GPS data and returns
+// a function restoring it
+func saveGlobals() (restore func()) {
+	counters := []*atomic.Int64{&parseErrors, &rejectedCoordinates, &rejectedHDOP, &rejectedSatellites, &firstFix, &lastFix, &fixLostAt, &fixAcquiredAt}
+	values := make([]int64, len(counters))
+	for i, c := range counters {
+		values[i] = c.Load()
+	}
+	anchor.m.Lock()
+	a := anchor
+	anchor.m.Unlock()
+	fixQualityAlarm.m.Lock()
+	q := fixQualityAlarm
+	q.durations = maps.Clone(q.durations)
+	fixQualityAlarm.m.Unlock()
+	receiver.m.Lock()
+	info := receiver
+	receiver.m.Unlock()
+	recent := events.recent()
+	fixRate.m.Lock()
+	fr := fixRate
+	fixRate.m.Unlock()
+
+	return func() {
+		for i, c := range counters {
+			c.Store(values[i])
+		}
+		anchor.m.Lock()
+		anchor = a
+		anchor.m.Unlock()
+		fixQualityAlarm.m.Lock()
+		fixQualityAlarm = q
+		fixQualityAlarm.m.Unlock()
+		receiver.m.Lock()
+		receiver = info
+		receiver.m.Unlock()
+		events.m.Lock()
+		events.events = recent
+		events.m.Unlock()
+		fixRate.m.Lock()
+		fixRate = fr
+		fixRate.m.Unlock()
+	}
+}
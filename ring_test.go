@@ -0,0 +1,69 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRingRecovery(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "ring")
+	r, err := openRing(name, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"$A", "$B", "$C", "$D"} {
+		r.write(s)
+	}
+	err = r.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Closed rings record nothing
+	r.write("$E")
+
+	r, err = openRing(name, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.close()
+	if got, want := r.sentences(), []string{"$B", "$C", "$D"}; !slices.Equal(got, want) {
+		t.Errorf("recovered %v, want %v", got, want)
+	}
+	r.write("$E")
+	if got, want := r.sentences(), []string{"$C", "$D", "$E"}; !slices.Equal(got, want) {
+		t.Errorf("continued with %v, want %v", got, want)
+	}
+}
+
+func TestReplayRingWithoutSideEffects(t *testing.T) {
+	r, err := openRing(filepath.Join(t.TempDir(), "ring"), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.close()
+	r.write(sentence("GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W"))
+	r.write(sentence("GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,"))
+	r.write(sentence("GPGGA,123520,9500.000,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,"))
+
+	old := snapshot()
+	defer store(old)
+	first, rejected, recent := firstFix.Load(), rejectedCoordinates.Load(), len(events.recent())
+	anchor.m.Lock()
+	anchorFix := anchor.fix
+	anchor.m.Unlock()
+
+	replayRing(r)
+	p := snapshot()
+	if !p.FromCache || p.fix || p.Satellites != 8 || p.Timestamp.IsZero() {
+		t.Errorf("replayed data FromCache %v, fix %v, satellites %v, timestamp %v", p.FromCache, p.fix, p.Satellites, p.Timestamp)
+	}
+	if firstFix.Load() != first || rejectedCoordinates.Load() != rejected || len(events.recent()) != recent {
+		t.Errorf("replay changed the first fix %v, rejected coordinates %v or events %v", firstFix.Load(), rejectedCoordinates.Load(), len(events.recent()))
+	}
+	anchor.m.Lock()
+	defer anchor.m.Unlock()
+	if anchor.fix != anchorFix {
+		t.Errorf("replay changed the fix of the anchor watch to %v", anchor.fix)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sentenceTypes are the NMEA sentence types the service recognizes and whether they are processed.
+// The others are parsed by the nmea package but skipped. RMC and GGA are only processed from the GP
+// and GN talkers, all other processed types from any talker.
+var sentenceTypes = map[string]bool{
+	"RMC": true,
+	"GGA": true,
+	"GSA": true,
+	"GSV": true,
+	"GST": true,
+	"ZDA": true,
+	"HDT": true,
+	"TXT": true,
+	"GLL": false,
+	"VTG": false,
+}
+
+// sentenceInfo is the JSON of a sentence type on /sentences
+type sentenceInfo struct {
+	Type      string
+	Supported bool
+	Processed bool
+	Count     int64
+	LastSeen  *time.Time `json:",omitempty"`
+	Talkers   []string   `json:",omitempty"`
+}
+
+// typeCount is the bookkeeping of one sentence type observed on the wire
+type typeCount struct {
+	count    int64
+	lastSeen time.Time
+	last     string // last raw sentence
+	talkers  map[string]bool
+}
+
+// typeCounts is the bookkeeping of all sentence types observed on the wire
+type typeCounts struct {
+	m     *sync.Mutex
+	types map[string]*typeCount
+}
+
+// seenSentences counts the sentence types read from the source
+var seenSentences = typeCounts{
+	m:     &sync.Mutex{},
+	types: map[string]*typeCount{},
+}
+
+// add counts the raw NMEA 'sentence' read at 'now'. Sentences without a valid address field,
+// e.g. proprietary ones, are ignored.
+func (c *typeCounts) add(now time.Time, sentence string) {
+	typ := sentenceType(sentence)
+	if typ == "" {
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	t := c.types[typ]
+	if t == nil {
+		t = &typeCount{talkers: map[string]bool{}}
+		c.types[typ] = t
+	}
+	t.count++
+	t.lastSeen = now
+	t.last = sentence
+	t.talkers[sentence[1:3]] = true
+}
+
+// list returns all recognized and all observed sentence types sorted by type
+func (c *typeCounts) list() []sentenceInfo {
+	c.m.Lock()
+	defer c.m.Unlock()
+	var l []sentenceInfo
+	for typ, processed := range sentenceTypes {
+		if c.types[typ] == nil {
+			l = append(l, sentenceInfo{Type: typ, Supported: true, Processed: processed})
+		}
+	}
+	for typ, t := range c.types {
+		processed, supported := sentenceTypes[typ]
+		lastSeen := t.lastSeen
+		talkers := make([]string, 0, len(t.talkers))
+		for talker := range t.talkers {
+			talkers = append(talkers, talker)
+		}
+		slices.Sort(talkers)
+		l = append(l, sentenceInfo{
+			Type:      typ,
+			Supported: supported,
+			Processed: processed,
+			Count:     t.count,
+			LastSeen:  &lastSeen,
+			Talkers:   talkers,
+		})
+	}
+	slices.SortFunc(l, func(a, b sentenceInfo) int { return strings.Compare(a.Type, b.Type) })
+	return l
+}
+
+// lastSentences returns the last raw sentence of each observed sentence type
+func (c *typeCounts) lastSentences() map[string]string {
+	c.m.Lock()
+	defer c.m.Unlock()
+	l := make(map[string]string, len(c.types))
+	for typ, t := range c.types {
+		l[typ] = t.last
+	}
+	return l
+}
+
+// HTTP Handler to send the recognized and observed sentence types as JSON
+func sentencesHandler(w http.ResponseWriter, r *http.Request) {
+	js, err := json.Marshal(seenSentences.list())
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// checksum returns the XOR checksum of the NMEA sentence body 's', without the leading '$' and
+// the '*HH' suffix, as two upper case hex digits.
+func checksum(s string) string {
+	var c byte
+	for i := 0; i < len(s); i++ {
+		c ^= s[i]
+	}
+	return fmt.Sprintf("%02X", c)
+}
+
+// splitSentence validates the checksum of the raw NMEA 'sentence' and splits it into talker ID,
+// sentence type and data fields. It is used for sentences the nmea parser does not support.
+func splitSentence(sentence string) (talker, typ string, fields []string, err error) {
+	fields, err = sentenceFields(sentence)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if len(fields[0]) != 5 {
+		return "", "", nil, fmt.Errorf("invalid address field %v", fields[0])
+	}
+	return fields[0][:2], fields[0][2:], fields[1:], nil
+}
+
+// sentenceFields validates the checksum of the raw NMEA 'sentence' and splits it into the address
+// field and data fields, also for proprietary sentences like PMTK705
+func sentenceFields(sentence string) ([]string, error) {
+	if !strings.HasPrefix(sentence, "$") && !strings.HasPrefix(sentence, "!") {
+		return nil, fmt.Errorf("sentence does not start with '$' or '!'")
+	}
+	body := sentence[1:]
+	i := strings.LastIndex(body, "*")
+	if i < 0 {
+		return nil, fmt.Errorf("sentence does not contain a checksum")
+	}
+	if sum := strings.ToUpper(body[i+1:]); sum != checksum(body[:i]) {
+		return nil, fmt.Errorf("checksum mismatch, sentence %v, calculated %v", sum, checksum(body[:i]))
+	}
+	return strings.Split(body[:i], ","), nil
+}
+
+// sentenceType returns the sentence type of the raw NMEA 'sentence' without the talker ID, e.g. GSV
+func sentenceType(sentence string) string {
+	i := strings.Index(sentence, ",")
+	if i != 6 {
+		return ""
+	}
+	return sentence[3:6]
+}
+
+// parseInt parses an integer NMEA field. Empty fields are zero.
+func parseInt(field string) (int64, error) {
+	if field == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(field, 10, 64)
+}
+
+// parseFloat parses a decimal NMEA field. Empty fields are zero.
+func parseFloat(field string) (float64, error) {
+	if field == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(field, 64)
+}
+
+// field returns the data field 'i' of the raw NMEA 'sentence' or "" if the sentence is shorter,
+// e.g. for fields added in later NMEA versions the nmea parser does not support
+func field(sentence string, i int) string {
+	_, _, fields, err := splitSentence(sentence)
+	if err != nil || i >= len(fields) {
+		return ""
+	}
+	return fields[i]
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/tarm/serial"
+)
+
+// Flow control modes of the serial connection
+const (
+	flowNone     = "none"
+	flowHardware = "hardware" // RTS/CTS
+	flowSoftware = "software" // XON/XOFF
+)
+
+// States of the DTR and RTS lines of --dtr and --rts
+const (
+	lineKeep = "keep"
+	lineOn   = "on"
+	lineOff  = "off"
+)
+
+// parities maps the --parity option to the serial library values
+var parities = map[string]serial.Parity{
+	"none":  serial.ParityNone,
+	"odd":   serial.ParityOdd,
+	"even":  serial.ParityEven,
+	"mark":  serial.ParityMark,
+	"space": serial.ParitySpace,
+}
+
+// stopBits maps the --stopbits option to the serial library values
+var stopBits = map[string]serial.StopBits{
+	"1":   serial.Stop1,
+	"1.5": serial.Stop1Half,
+	"2":   serial.Stop2,
+}
+
+// splitTTY splits a --tty of the form /dev/ttyUSB0@9600 into the device and its baud rate. Without
+// a baud rate 'baud' is returned.
+func splitTTY(tty string, baud int) (string, int, error) {
+	name, rate, ok := strings.Cut(tty, "@")
+	if !ok {
+		return tty, baud, nil
+	}
+	baud, err := strconv.Atoi(rate)
+	if err != nil || baud <= 0 {
+		return "", 0, fmt.Errorf("invalid baud rate %q of tty %v, must be a positive number", rate, name)
+	}
+	return name, baud, nil
+}
+
+// serialConfig builds the serial configuration from the command line options and validates
+// the combination of data bits, parity and stop bits.
+func serialConfig() (*serial.Config, error) {
+	if *databits < 5 || *databits > 8 {
+		return nil, fmt.Errorf("invalid number of data bits %v, must be between 5 and 8", *databits)
+	}
+	// The serial library only supports mark and space parity and 1.5 stop bits on Windows
+	if runtime.GOOS != "windows" {
+		if *parity == "mark" || *parity == "space" {
+			return nil, fmt.Errorf("invalid parity %v, must be none, odd or even on %v", *parity, runtime.GOOS)
+		}
+		if *stopbits == "1.5" {
+			return nil, fmt.Errorf("invalid stop bits %v, must be 1 or 2 on %v", *stopbits, runtime.GOOS)
+		}
+	}
+	// 1.5 stop bits are only defined for 5 data bits
+	if *stopbits == "1.5" && *databits != 5 {
+		return nil, fmt.Errorf("1.5 stop bits require 5 data bits, got %v", *databits)
+	}
+
+	return &serial.Config{
+		Name:        *tty,
+		Baud:        *baudrate,
+		ReadTimeout: *readTimeout,
+		Size:        byte(*databits),
+		Parity:      parities[*parity],
+		StopBits:    stopBits[*stopbits],
+	}, nil
+}
+
+// openTTY opens the serial connection given by --tty. If --tty is a regular file, e.g. by accident,
+// it is read as it is and reaching its end is final.
+func openTTY() (input, error) {
+	fi, err := os.Stat(*tty)
+	if err == nil && fi.Mode().IsRegular() {
+		log.Printf("%v is a regular file and not a serial device", *tty)
+		f, err := os.Open(*tty)
+		return input{ReadCloser: f}, err
+	}
+
+	c, err := serialConfig()
+	if err != nil {
+		return input{}, err
+	}
+	s, err := serial.OpenPort(c)
+	if err != nil {
+		return input{}, diagnoseTTY(*tty, err)
+	}
+	err = setFlowControl(*tty, *flowControl)
+	if err != nil {
+		s.Close()
+		return input{}, err
+	}
+	err = setModemLines(*tty, *dtr, *rts)
+	if err != nil {
+		s.Close()
+		return input{}, fmt.Errorf("can't set DTR and RTS of %v, %v", *tty, err)
+	}
+	err = sendInitCommands(s)
+	if err != nil {
+		s.Close()
+		return input{}, err
+	}
+	openSerialErrors(*tty)
+	return input{ReadCloser: s, w: s, retryEOF: true, reopen: true}, nil
+}
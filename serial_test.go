@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSerialConfig(t *testing.T) {
+	tests := []struct {
+		databits         int
+		parity, stopbits string
+		ok               bool
+	}{
+		{8, "none", "1", true},
+		{7, "even", "1", true},
+		{8, "odd", "2", true},
+		{4, "none", "1", false},
+		{9, "none", "1", false},
+		{5, "none", "1.5", runtime.GOOS == "windows"},
+		{8, "none", "1.5", false},
+		{8, "mark", "1", runtime.GOOS == "windows"},
+		{8, "space", "1", runtime.GOOS == "windows"},
+	}
+	oldDatabits, oldParity, oldStopbits := *databits, *parity, *stopbits
+	defer func() { *databits, *parity, *stopbits = oldDatabits, oldParity, oldStopbits }()
+	for _, tt := range tests {
+		*databits, *parity, *stopbits = tt.databits, tt.parity, tt.stopbits
+		_, err := serialConfig()
+		if (err == nil) != tt.ok {
+			t.Errorf("serialConfig() of %v%v%v returned %v, want ok %v", tt.databits, tt.parity, tt.stopbits, err, tt.ok)
+		}
+	}
+}
+
+func TestSerialErrorsWithoutCounters(t *testing.T) {
+	defer closeSerialErrors()
+	path := filepath.Join(t.TempDir(), "tty")
+	err := os.WriteFile(path, nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A device without the counters is not kept open
+	openSerialErrors(path)
+	if _, ok := serialErrors(); ok || serialCounters.f != nil {
+		t.Errorf("serial errors reported for %v without counters", path)
+	}
+}
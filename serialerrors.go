@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"sync"
+)
+
+// serialErrorCounts are the error counters of the serial driver. A rising Overrun or BufferOverrun
+// count means the data is not read fast enough, rising Frame or Parity counts point to a wrong
+// baud rate or a bad cable.
+type serialErrorCounts struct {
+	Frame         int64
+	Overrun       int64 // the UART lost data
+	Parity        int64
+	Break         int64
+	BufferOverrun int64 // the driver buffer lost data
+}
+
+// serialCounters is a second file descriptor of the opened serial device for reading its error
+// counters, so /stats and /metrics don't open the device on every request. It is nil while no
+// serial device is open or its driver does not provide the counters.
+var serialCounters = struct {
+	m *sync.Mutex
+	f *os.File
+}{m: &sync.Mutex{}}
+
+// openSerialErrors keeps a file descriptor of the just opened serial device 'name' for serialErrors
+func openSerialErrors(name string) {
+	f := openSerialCounters(name)
+	serialCounters.m.Lock()
+	defer serialCounters.m.Unlock()
+	if serialCounters.f != nil {
+		serialCounters.f.Close()
+	}
+	serialCounters.f = f
+}
+
+// closeSerialErrors closes the file descriptor of openSerialErrors once the source is closed
+func closeSerialErrors() {
+	serialCounters.m.Lock()
+	defer serialCounters.m.Unlock()
+	if serialCounters.f != nil {
+		serialCounters.f.Close()
+		serialCounters.f = nil
+	}
+}
+
+// serialErrors returns the error counters of the serial device --tty, false if the source is not
+// a serial device or the platform or driver does not provide them
+func serialErrors() (serialErrorCounts, bool) {
+	serialCounters.m.Lock()
+	defer serialCounters.m.Unlock()
+	if serialCounters.f == nil {
+		return serialErrorCounts{}, false
+	}
+	return readSerialErrors(serialCounters.f)
+}
@@ -0,0 +1,52 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// tiocgicount reads the interrupt counters of a serial device, it is missing in the syscall package
+const tiocgicount = 0x545D
+
+// serialICounter is struct serial_icounter_struct of linux/serial.h
+type serialICounter struct {
+	cts, dsr, rng, dcd int32
+	rx, tx             int32
+	frame, overrun     int32
+	parity, brk        int32
+	bufOverrun         int32
+	reserved           [9]int32
+}
+
+// openSerialCounters opens a second file descriptor of the serial device 'name' for reading the
+// error counters, the serial library does not expose its own. Drivers without the counters, e.g.
+// of many USB adapters, are skipped and nil is returned.
+func openSerialCounters(name string) *os.File {
+	f, err := os.OpenFile(name, syscall.O_RDONLY|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil
+	}
+	if _, ok := readSerialErrors(f); !ok {
+		f.Close()
+		return nil
+	}
+	return f
+}
+
+// readSerialErrors reads the error counters of the serial device 'f'
+func readSerialErrors(f *os.File) (serialErrorCounts, bool) {
+	var c serialICounter
+	if err := ioctl(f.Fd(), tiocgicount, uintptr(unsafe.Pointer(&c))); err != nil {
+		return serialErrorCounts{}, false
+	}
+	return serialErrorCounts{
+		Frame:         int64(c.frame),
+		Overrun:       int64(c.overrun),
+		Parity:        int64(c.parity),
+		Break:         int64(c.brk),
+		BufferOverrun: int64(c.bufOverrun),
+	}, true
+}
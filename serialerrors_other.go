@@ -0,0 +1,15 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// openSerialCounters is only implemented for Linux, other platforms report no counters
+func openSerialCounters(name string) *os.File {
+	return nil
+}
+
+// readSerialErrors is only implemented for Linux, other platforms report no counters
+func readSerialErrors(f *os.File) (serialErrorCounts, bool) {
+	return serialErrorCounts{}, false
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// sinkQueue is the number of updates queued per sink before --sink-backpressure applies
+const sinkQueue = 64
+
+// Policies of --sink-backpressure for a full sink queue
+const (
+	sinkDropNewest = "drop-newest" // the new update is dropped
+	sinkDropOldest = "drop-oldest" // the oldest queued update is dropped for the new one
+	sinkBlock      = "block"       // the parsing waits up to --sink-block-timeout, then drops the new one
+)
+
+// Sink is an output of the GPS data, e.g. the track, a database or a webhook. Each sink runs in
+// its own go routine, so Publish may block without stalling the parsing.
+type Sink interface {
+	Publish(ctx context.Context, p data) error
+}
+
+// registeredSink is a sink with its queue of updates
+type registeredSink struct {
+	name      string
+	sink      Sink
+	positions bool // only updates with a new position are published
+	queue     chan data
+	done      <-chan struct{} // closed when the sinks are stopped, nil before startSinks
+	dropped   int64           // guarded by sinksMutex
+	logged    time.Time
+}
+
+var (
+	// sinks are all registered outputs, updateGPS publishes to them whenever 'd' is stored
+	sinks []*registeredSink
+	// sinksMutex guards the drop counters of the sinks
+	sinksMutex = &sync.Mutex{}
+)
+
+// registerSink registers the output 's' as 'name'. With 'positions' it only receives updates
+// with a new position. Sinks must be registered before startSinks.
+func registerSink(name string, s Sink, positions bool) {
+	sinks = append(sinks, &registeredSink{name: name, sink: s, positions: positions, queue: make(chan data, sinkQueue)})
+}
+
+// startSinks publishes the queued updates to every sink until the returned function stops them.
+// Errors are logged at most every reconnectLogInterval per sink.
+func startSinks() (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	for _, s := range sinks {
+		s.done = done
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var logged time.Time
+			publish := func(p data) {
+				err := s.sink.Publish(ctx, p)
+				if err != nil && time.Since(logged) >= reconnectLogInterval {
+					log.Printf("Error while publishing to %v, %v", s.name, err)
+					logged = time.Now()
+				}
+			}
+			for {
+				select {
+				case <-done:
+					// The queued updates are published before the sink stops
+					for {
+						select {
+						case p := <-s.queue:
+							publish(p)
+						default:
+							return
+						}
+					}
+				case p := <-s.queue:
+					publish(p)
+				}
+			}
+		}()
+	}
+	return func() {
+		close(done)
+		stopped := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(shutdownTimeout):
+			log.Printf("Warning: outputs did not finish within %v", shutdownTimeout)
+		}
+		cancel()
+	}
+}
+
+// stopped returns true once the sinks are stopped
+func (s *registeredSink) stopped() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// enqueue queues 'p' according to --sink-backpressure and returns false if an update was dropped
+func (s *registeredSink) enqueue(p data) bool {
+	select {
+	case s.queue <- p:
+		return true
+	default:
+	}
+	switch *sinkBackpressure {
+	case sinkDropOldest:
+		// The sink may take the oldest update meanwhile, then the new one fits without a drop
+		dropped := false
+		select {
+		case <-s.queue:
+			dropped = true
+		default:
+		}
+		select {
+		case s.queue <- p:
+			return !dropped
+		default:
+			return false
+		}
+	case sinkBlock:
+		timer := time.NewTimer(*sinkBlockTimeout)
+		defer timer.Stop()
+		// Nothing takes from the queue once the sinks are stopped
+		select {
+		case s.queue <- p:
+			return true
+		case <-timer.C:
+			return false
+		case <-s.done:
+			return false
+		}
+	}
+	return false
+}
+
+// publishSinks queues 'p' for all sinks, 'moved' tells whether the position changed. If a sink
+// falls behind an update is dropped for it, see --sink-backpressure. Stopped sinks are skipped.
+func publishSinks(p data, moved bool) {
+	for _, s := range sinks {
+		if s.positions && !moved || s.stopped() {
+			continue
+		}
+		if !s.enqueue(p) {
+			sinksMutex.Lock()
+			s.dropped++
+			if time.Since(s.logged) >= reconnectLogInterval {
+				log.Printf("Warning: %v is too slow, %v updates dropped", s.name, s.dropped)
+				s.logged = time.Now()
+			}
+			sinksMutex.Unlock()
+		}
+	}
+}
+
+// droppedUpdates returns the number of dropped updates per sink
+func droppedUpdates() map[string]int64 {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+	dropped := map[string]int64{}
+	for _, s := range sinks {
+		dropped[s.name] = s.dropped
+	}
+	return dropped
+}
+
+// queuedUpdates returns the number of updates queued per sink
+func queuedUpdates() map[string]int64 {
+	queued := map[string]int64{}
+	for _, s := range sinks {
+		queued[s.name] = int64(len(s.queue))
+	}
+	return queued
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowSink records the satellites of every update and sleeps 'delay' per update
+type slowSink struct {
+	m     *sync.Mutex
+	delay time.Duration
+	got   []int64
+}
+
+func (s *slowSink) Publish(ctx context.Context, p data) error {
+	time.Sleep(s.delay)
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.got = append(s.got, p.Satellites)
+	return nil
+}
+
+// received waits until the sink got 'n' updates or a second passed and returns them
+func (s *slowSink) received(n int) []int64 {
+	for end := time.Now().Add(time.Second); time.Now().Before(end); time.Sleep(time.Millisecond) {
+		s.m.Lock()
+		l := len(s.got)
+		s.m.Unlock()
+		if l >= n {
+			break
+		}
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	return slices.Clone(s.got)
+}
+
+// setBackpressure sets the policy and timeout and restores them at the end of the test
+func setBackpressure(t *testing.T, policy string, timeout time.Duration) {
+	oldPolicy, oldTimeout := *sinkBackpressure, *sinkBlockTimeout
+	*sinkBackpressure, *sinkBlockTimeout = policy, timeout
+	t.Cleanup(func() { *sinkBackpressure, *sinkBlockTimeout = oldPolicy, oldTimeout })
+	old := sinks
+	sinks = nil
+	t.Cleanup(func() { sinks = old })
+}
+
+// sequence returns the numbers from 'from' to 'to' excluding 'to'
+func sequence(from, to int64) []int64 {
+	var l []int64
+	for i := from; i < to; i++ {
+		l = append(l, i)
+	}
+	return l
+}
+
+func TestBackpressureFullQueue(t *testing.T) {
+	const overflow = 10
+	tests := []struct {
+		policy string
+		want   []int64
+	}{
+		{sinkDropNewest, sequence(0, sinkQueue)},
+		{sinkDropOldest, sequence(overflow, sinkQueue+overflow)},
+		{sinkBlock, sequence(0, sinkQueue)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			setBackpressure(t, tt.policy, time.Millisecond)
+			s := &slowSink{m: &sync.Mutex{}, delay: time.Millisecond}
+			registerSink("slow", s, false)
+
+			// The sink is not started yet, so its queue overflows
+			for i := range int64(sinkQueue + overflow) {
+				publishSinks(data{Satellites: i}, true)
+			}
+			if got := droppedUpdates()["slow"]; got != overflow {
+				t.Errorf("dropped %v updates, want %v", got, overflow)
+			}
+			if got := queuedUpdates()["slow"]; got != sinkQueue {
+				t.Errorf("queued %v updates, want %v", got, sinkQueue)
+			}
+
+			stop := startSinks()
+			defer stop()
+			if got := s.received(len(tt.want)); !slices.Equal(got, tt.want) {
+				t.Errorf("sink received %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackpressureBlockWaitsForSlowSink(t *testing.T) {
+	setBackpressure(t, sinkBlock, time.Second)
+	s := &slowSink{m: &sync.Mutex{}, delay: time.Millisecond}
+	registerSink("slow", s, false)
+	stop := startSinks()
+	defer stop()
+
+	const n = 2 * sinkQueue
+	for i := range int64(n) {
+		publishSinks(data{Satellites: i}, true)
+	}
+	if got := droppedUpdates()["slow"]; got != 0 {
+		t.Errorf("dropped %v updates, want none", got)
+	}
+	if got := s.received(n); !slices.Equal(got, sequence(0, n)) {
+		t.Errorf("sink received %v, want all %v in order", got, n)
+	}
+}
+
+func TestBackpressureBlockStoppedSinks(t *testing.T) {
+	setBackpressure(t, sinkBlock, time.Minute)
+	s := &slowSink{m: &sync.Mutex{}}
+	registerSink("slow", s, false)
+	startSinks()()
+
+	start := time.Now()
+	for i := range int64(sinkQueue + 2) {
+		publishSinks(data{Satellites: i}, true)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("publishing to stopped sinks took %v", d)
+	}
+}
+
+func TestStopSinksDrainsQueue(t *testing.T) {
+	setBackpressure(t, sinkDropNewest, 0)
+	s := &slowSink{m: &sync.Mutex{}, delay: time.Millisecond}
+	registerSink("slow", s, false)
+	for i := range int64(sinkQueue) {
+		publishSinks(data{Satellites: i}, true)
+	}
+	startSinks()()
+
+	// All queued updates are published once stop returns
+	s.m.Lock()
+	defer s.m.Unlock()
+	if !slices.Equal(s.got, sequence(0, sinkQueue)) {
+		t.Errorf("sink received %v, want all %v queued updates", s.got, sinkQueue)
+	}
+}
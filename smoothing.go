@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// movingAverage is the average of the last 'size' values
+type movingAverage struct {
+	size   int
+	values []float64
+}
+
+// add adds the value 'v' and returns the current average
+func (a *movingAverage) add(v float64) float64 {
+	a.values = append(a.values, v)
+	if len(a.values) > a.size {
+		a.values = a.values[len(a.values)-a.size:]
+	}
+	return a.mean()
+}
+
+// mean returns the current average, 0 without values
+func (a *movingAverage) mean() float64 {
+	if len(a.values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range a.values {
+		sum += v
+	}
+	return sum / float64(len(a.values))
+}
+
+// reset drops all values, e.g. after the fix was lost
+func (a *movingAverage) reset() {
+	a.values = a.values[:0]
+}
+
+// headingFilter is a low-pass filter of the course with the time constant 'tau'. It filters the
+// unit vector of the course, so 359° and 1° average to 0° and not to 180°.
+type headingFilter struct {
+	tau  time.Duration
+	x, y float64   // filtered unit vector
+	last time.Time // time of the last value, zero after a reset
+}
+
+// add adds the course 'course' in degrees at 'now' and returns the filtered course in degrees
+func (f *headingFilter) add(now time.Time, course float64) float64 {
+	θ := course * math.Pi / 180
+	if f.last.IsZero() || f.tau <= 0 {
+		f.x, f.y = math.Cos(θ), math.Sin(θ)
+	} else {
+		α := 1 - math.Exp(-now.Sub(f.last).Seconds()/f.tau.Seconds())
+		f.x += α * (math.Cos(θ) - f.x)
+		f.y += α * (math.Sin(θ) - f.y)
+	}
+	f.last = now
+	return mod360(math.Atan2(f.y, f.x) * 180 / math.Pi)
+}
+
+// reset restarts the filter, e.g. when the course is not valid
+func (f *headingFilter) reset() {
+	f.last = time.Time{}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sources of the NMEA sentences selected by --source
+const (
+	sourceSerial    = "serial"
+	sourceStdin     = "stdin"
+	sourceTCP       = "tcp"
+	sourceBluetooth = "bluetooth"
+)
+
+// errNoData is returned by updateGPS after --max-timeouts consecutive read timeouts
+var errNoData = errors.New("no data received")
+
+// errRetriesExceeded is returned by readGPS after --max-connect-retries failed reconnects
+var errRetriesExceeded = errors.New("too many failed reconnects")
+
+// exitRetriesExceeded is the exit code after --max-connect-retries failed reconnects, so
+// supervisors and scripts can tell it apart from other errors
+const exitRetriesExceeded = 3
+
+// input is an opened source of NMEA sentences
+type input struct {
+	io.ReadCloser
+	w        io.Writer // the receiver for commands, nil if the source can't be written to
+	retryEOF bool      // io.EOF is a read timeout, as reported by serial devices
+	reopen   bool      // the source is reopened if it stops delivering data
+	backoff  bool      // reconnects are retried with an increasing delay
+}
+
+// openSource opens the source of the NMEA sentences given by --source or --replay
+func openSource() (input, error) {
+	if *replayFile != "" {
+		return openReplay()
+	}
+	switch *source {
+	case sourceStdin:
+		return input{ReadCloser: os.Stdin}, nil
+	case sourceTCP:
+		c, err := net.DialTimeout("tcp", *address, *readTimeout)
+		if err != nil {
+			return input{}, err
+		}
+		err = sendInitCommands(c)
+		if err != nil {
+			c.Close()
+			return input{}, err
+		}
+		return input{ReadCloser: deadlineConn{Conn: c, timeout: *readTimeout}, w: c, reopen: true}, nil
+	case sourceBluetooth:
+		return openBluetooth()
+	default:
+		return openTTY()
+	}
+}
+
+// sourceName returns a human readable name of the source for messages
+func sourceName() string {
+	if *replayFile != "" {
+		return *replayFile
+	}
+	switch *source {
+	case sourceStdin:
+		return "stdin"
+	case sourceTCP:
+		return *address
+	default:
+		return *tty
+	}
+}
+
+// deadlineConn sets a read deadline before every read, so a half-open connection fails with a
+// timeout instead of blocking forever
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+// Read reads from the connection with a deadline
+func (c deadlineConn) Read(b []byte) (int, error) {
+	err := c.SetReadDeadline(time.Now().Add(c.timeout))
+	if err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+// isTimeout returns true if 'err' is a read timeout of 'in'
+func isTimeout(in input, err error) bool {
+	var ne net.Error
+	return (err == io.EOF && in.retryEOF) || (errors.As(err, &ne) && ne.Timeout())
+}
+
+// reconnectLogInterval limits how often failing reconnects are logged
+const reconnectLogInterval = time.Minute
+
+// connection is the state of the connection to the source, safe for concurrent use
+type connection struct {
+	m         *sync.Mutex
+	connected bool
+	attempts  int64 // consecutive failed reconnects
+	lastError string
+	logged    time.Time
+}
+
+// conn is the state of the connection to the source
+var conn = connection{
+	m: &sync.Mutex{},
+}
+
+// state returns whether the source is connected, the number of consecutive failed reconnects
+// and the last reconnect error
+func (c *connection) state() (connected bool, attempts int64, lastError string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.connected, c.attempts, c.lastError
+}
+
+// up records that the source is connected
+func (c *connection) up() {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.connected = true
+	c.attempts = 0
+	c.lastError = ""
+}
+
+// down records that the source is disconnected
+func (c *connection) down() {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.connected = false
+}
+
+// failed records a failed reconnect. The error is logged at most every reconnectLogInterval.
+func (c *connection) failed(err error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.attempts++
+	c.lastError = err.Error()
+	if time.Since(c.logged) >= reconnectLogInterval {
+		log.Printf("Error while reconnecting to %v, %v failed attempts, %v", sourceName(), c.attempts, err)
+		c.logged = time.Now()
+	}
+}
+
+// readGPS keeps 'd' up to date from the opened source until reading fails permanently. Sources
+// that stop delivering data are reopened, up to --max-connect-retries failed attempts.
+func readGPS(in input) error {
+	conn.up()
+	for {
+		queries.connect(in.w)
+		err := updateGPS(in)
+		queries.connect(nil)
+		in.Close()
+		closeSerialErrors()
+		conn.down()
+		if !in.reopen || (err != errNoData && err != io.EOF && !errors.Is(err, errHangup)) {
+			return err
+		}
+
+		// Reconnect until the source is available again
+		event(eventConnection, "lost %v, %v", sourceName(), err)
+		delay, backoff := retryDelay, in.backoff
+		for {
+			time.Sleep(delay)
+			in, err = openSource()
+			if err == nil {
+				break
+			}
+			conn.failed(err)
+			if _, attempts, _ := conn.state(); *maxConnectRetries > 0 && attempts >= int64(*maxConnectRetries) {
+				return fmt.Errorf("%w, %v attempts, %v", errRetriesExceeded, attempts, err)
+			}
+			if backoff {
+				delay = min(2*delay, bluetoothMaxDelay)
+			}
+		}
+		_, attempts, _ := conn.state()
+		event(eventConnection, "reconnected to %v after %v failed attempts", sourceName(), attempts)
+		conn.up()
+	}
+}
+
+// liveSource returns false if the sentences are read from a file or stdin, which never lose data
+// while the processing is behind
+func liveSource() bool {
+	return *replayFile == "" && *source != sourceStdin
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const (
+	sqliteInterval = 5 * time.Second // Interval for writing the collected fixes in one transaction
+	sqliteBatch    = 1024            // Number of fixes that are written at the latest
+)
+
+// sqliteSchema creates the positions table if it does not exist yet
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS positions (
+	timestamp TEXT NOT NULL,
+	latitude REAL NOT NULL,
+	longitude REAL NOT NULL,
+	altitude REAL,
+	speed REAL,
+	satellites INTEGER,
+	hdop REAL
+)`
+
+// sqliteInsert inserts one fix into the positions table
+const sqliteInsert = `INSERT INTO positions (timestamp, latitude, longitude, altitude, speed, satellites, hdop)
+VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+// positionDB writes the recorded fixes to the SQLite database of --sqlite. The fixes are collected
+// and written in batches, so there is no I/O per fix.
+type positionDB struct {
+	m       *sync.Mutex
+	db      *sql.DB
+	batch   []data
+	written time.Time
+	done    chan struct{} // closed by close to stop flush
+}
+
+// positions is the SQLite database of --sqlite, nil if unset
+var positions *positionDB
+
+// openSQLite opens or creates the SQLite database 'path'
+func openSQLite(path string) (*positionDB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(sqliteSchema)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	p := &positionDB{m: &sync.Mutex{}, db: db, written: time.Now(), done: make(chan struct{})}
+	go p.flush()
+	return p, nil
+}
+
+// flush writes the collected fixes every sqliteInterval, also while no recordable fixes arrive
+func (p *positionDB) flush() {
+	ticker := time.NewTicker(sqliteInterval)
+	defer ticker.Stop()
+	var logged time.Time
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+		p.m.Lock()
+		var err error
+		if time.Since(p.written) >= sqliteInterval {
+			err = p.write()
+		}
+		p.m.Unlock()
+		if err != nil && time.Since(logged) >= reconnectLogInterval {
+			log.Printf("Error while writing to the SQLite database, %v", err)
+			logged = time.Now()
+		}
+	}
+}
+
+// Publish collects the fix of 'p' if it is recorded to the track as well, and writes the collected
+// fixes every sqliteInterval
+func (p *positionDB) Publish(ctx context.Context, fix data) error {
+	if !recordable(fix) {
+		return nil
+	}
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.batch = append(p.batch, fix)
+	if time.Since(p.written) < sqliteInterval && len(p.batch) < sqliteBatch {
+		return nil
+	}
+	return p.write()
+}
+
+// write inserts the collected fixes in one transaction. On errors the fixes are lost.
+func (p *positionDB) write() error {
+	batch := p.batch
+	p.batch = nil
+	p.written = time.Now()
+	if len(batch) == 0 {
+		return nil
+	}
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(sqliteInsert)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, fix := range batch {
+		_, err = stmt.Exec(fix.Timestamp.Format(time.RFC3339), fix.Latitude, fix.Longitude, fix.Altitude, fix.Speed, fix.Satellites, fix.HDOP)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// close writes the collected fixes and closes the database
+func (p *positionDB) close() error {
+	close(p.done)
+	p.m.Lock()
+	defer p.m.Unlock()
+	err := p.write()
+	if err != nil {
+		log.Printf("Error while writing to the SQLite database, %v", err)
+	}
+	return p.db.Close()
+}
@@ -0,0 +1,46 @@
+package main
+
+import "time"
+
+// staleCheckInterval is the interval of checking the served data for a stale speed while no
+// sentences arrive, e.g. with a dead receiver
+const staleCheckInterval = time.Second
+
+// resetStaleMotion resets the speed, the course and Moving of 'p' if the speed was not updated by
+// a valid RMC for --motion-timeout, so a parked vehicle with a dead GPS does not look like it is
+// moving. The position is kept. It returns true if anything was reset.
+func resetStaleMotion(p *data, now time.Time) bool {
+	if *motionTimeout <= 0 || p.Updated.Speed.IsZero() || now.Sub(p.Updated.Speed) < *motionTimeout {
+		return false
+	}
+	if p.Speed == 0 && p.SpeedSmoothed == 0 && !p.CourseValid && !p.Moving {
+		return false
+	}
+	p.Speed, p.SpeedSmoothed = 0, 0
+	p.CourseValid = false
+	p.Moving = false
+	return true
+}
+
+// resetStaleData resets the motion of 'd' while no sentences update it. The change of Moving is
+// reported by the updater once sentences arrive again.
+func resetStaleData() {
+	for range time.Tick(staleCheckInterval) {
+		checkStaleData(time.Now())
+	}
+}
+
+// checkStaleData resets the motion of 'd' if it is stale at 'now' and publishes the reset to the
+// sinks without a new position, e.g. to /stream and the deltas
+func checkStaleData(now time.Time) {
+	d.m.Lock()
+	reset := resetStaleMotion(&d, now)
+	if reset {
+		d.stored = now
+	}
+	p := d
+	d.m.Unlock()
+	if reset {
+		publishSinks(p, false)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStaleDataPublished(t *testing.T) {
+	setBackpressure(t, sinkDropNewest, 0)
+	old, oldTimeout := d, *motionTimeout
+	defer func() { d, *motionTimeout = old, oldTimeout }()
+	*motionTimeout = time.Minute
+	now := time.Now()
+	d = data{m: &sync.Mutex{}, Speed: 50, Moving: true, Satellites: 8}
+	d.Updated.Speed = now.Add(-2 * time.Minute)
+	s := &slowSink{m: &sync.Mutex{}}
+	registerSink("stream", s, false)
+	positions := &slowSink{m: &sync.Mutex{}}
+	registerSink("track", positions, true)
+	stop := startSinks()
+
+	checkStaleData(now)
+	if got := s.received(1); !slices.Equal(got, []int64{8}) {
+		t.Errorf("stream received %v, want the reset update", got)
+	}
+	// Nothing changed since the reset, the stop publishes all queued updates
+	checkStaleData(now.Add(time.Second))
+	stop()
+	if got := s.received(2); len(got) != 1 {
+		t.Errorf("stream received %v, want only the reset update", got)
+	}
+	if got := positions.received(0); len(got) != 0 {
+		t.Errorf("track received %v without a new position", got)
+	}
+	if d.Speed != 0 || d.Moving {
+		t.Errorf("speed %v, moving %v after the reset", d.Speed, d.Moving)
+	}
+}
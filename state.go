@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+)
+
+const stateInterval = 30 * time.Second // interval for writing the last known position to the state file
+
+// loadState restores the last known position from the state file 'path' into 'd'. It is
+// flagged with FromCache and keeps its original timestamp. A missing file is not an error.
+// The state file has the real position, it is fuzzed like any stored position.
+func loadState(path string) error {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var s data
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+
+	d.m.Lock()
+	d.Fuzzed = false
+	d.Timestamp = s.Timestamp
+	d.TimestampLocal = s.Timestamp.In(location)
+	d.update = s.Timestamp
+	setCoordinates(&d, s.Latitude, s.Longitude)
+	d.Altitude = s.Altitude
+	d.AltitudeValid = s.Altitude != nil
+	d.Satellites = s.Satellites
+	// The speed is not restored
+	d.Updated = fieldUpdates{Position: s.Updated.Position, Altitude: s.Updated.Altitude, Satellites: s.Updated.Satellites}
+	d.FromCache = true
+	d.stored = time.Now()
+	// The state file has the real position
+	unfuzzed.latitude, unfuzzed.longitude = s.Latitude, s.Longitude
+	d = fuzz(d)
+	d.m.Unlock()
+	return nil
+}
+
+// saveState periodically writes the last good fix with its real position to the state file 'path'.
+func saveState(path string) {
+	for range time.Tick(stateInterval) {
+		d.m.Lock()
+		fresh := d.fix && !d.FromCache
+		b, err := json.Marshal(unfuzz(d))
+		d.m.Unlock()
+		if !fresh {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error while encoding state, %v", err)
+			continue
+		}
+
+		// Write to a temporary file first so a crash never leaves a truncated state file
+		err = os.WriteFile(path+".tmp", b, 0644)
+		if err == nil {
+			err = os.Rename(path+".tmp", path)
+		}
+		if err != nil {
+			log.Printf("Error while writing state file, %v", err)
+		}
+	}
+}
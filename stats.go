@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counters of this process, safe for concurrent use
+var (
+	started             = time.Now()
+	sentences           atomic.Int64 // sentences read from the GPS sensor
+	readErrors          atomic.Int64 // errors while reading from the GPS sensor
+	droppedSentences    atomic.Int64 // sentences dropped as the processing could not keep up
+	queuedSentences     atomic.Int64 // sentences read but not processed yet
+	parseErrors         atomic.Int64 // sentences that could not be parsed
+	rejectedCoordinates atomic.Int64 // fixes rejected due to out of range coordinates
+	rejectedHDOP        atomic.Int64 // fixes rejected due to an HDOP above --max-hdop
+	rejectedSatellites  atomic.Int64 // fixes rejected due to fewer satellites than --min-satellites
+	webhooksPosted      atomic.Int64 // payloads posted to --webhook
+	webhooksSuppressed  atomic.Int64 // position payloads suppressed in the same geohash cell
+	degradedSentences   atomic.Int64 // sentences dropped or altered by --degrade-*
+	firstFix            atomic.Int64 // time of the first fix in unix nanoseconds, 0 before
+	lastFix             atomic.Int64 // time of the last fix in unix nanoseconds, 0 before
+	fixLostAt           atomic.Int64 // time the fix was last lost in unix nanoseconds, 0 if never
+	fixAcquiredAt       atomic.Int64 // time the fix was last acquired in unix nanoseconds, 0 if never
+	subscribers         atomic.Int64 // connected clients of /stream, --binary-listen and --grpc-listen
+	fixRate             = rate{m: &sync.Mutex{}}
+)
+
+// rateWindow is the number of seconds 'rate' averages over
+const rateWindow = 60
+
+// rate counts events in per second buckets over the last rateWindow seconds
+type rate struct {
+	m       *sync.Mutex
+	counts  [rateWindow]int64
+	seconds [rateWindow]int64 // unix time of the bucket, older buckets are outdated
+}
+
+// add counts an event at 'now'
+func (r *rate) add(now time.Time) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	sec := now.Unix()
+	i := sec % rateWindow
+	if r.seconds[i] != sec {
+		r.seconds[i] = sec
+		r.counts[i] = 0
+	}
+	r.counts[i]++
+}
+
+// perSecond returns the average number of events per second up to 'now'
+func (r *rate) perSecond(now time.Time) float64 {
+	r.m.Lock()
+	defer r.m.Unlock()
+	sec := now.Unix()
+	sum := int64(0)
+	for i := range r.counts {
+		if sec-r.seconds[i] < rateWindow {
+			sum += r.counts[i]
+		}
+	}
+	// Right after the start there are less than rateWindow seconds to average over
+	window := min(float64(rateWindow), now.Sub(started).Seconds())
+	if window < 1 {
+		window = 1
+	}
+	return float64(sum) / window
+}
+
+// parseError logs and counts a sentence that could not be parsed
+func parseError(sentence string, err error) {
+	parseErrors.Add(1)
+	log.Printf("Error while parsing '%v', %v", sentence, err)
+}
+
+// countFix records a valid fix at 'now' for the statistics
+func countFix(now time.Time) {
+	firstFix.CompareAndSwap(0, now.UnixNano())
+	lastFix.Store(now.UnixNano())
+	fixRate.add(now)
+}
+
+// statistics is the JSON of the /stats endpoint. The field names are stable for scripting.
+type statistics struct {
+	Sentences           int64
+	ReadErrors          int64
+	DroppedSentences    int64
+	QueuedSentences     int64
+	ParseErrors         int64
+	RejectedCoordinates int64
+	RejectedHDOP        int64
+	RejectedSatellites  int64
+	WebhooksPosted      int64
+	WebhooksSuppressed  int64
+	DegradedSentences   int64
+	Uptime              time.Duration
+	FixesPerSecond      float64
+	TTFF                time.Duration            // time to first fix since start, 0 without fix
+	FixAge              time.Duration            // time since the last fix, 0 without fix
+	FixLostAt           time.Time                // time the fix was last lost, zero if never
+	FixAcquiredAt       time.Time                // time the fix was last acquired, zero if never
+	FixQualityAlarm     bool                     // FixQuality is below --min-fix-quality
+	FixQualityDurations map[string]time.Duration // time spent in each GGA fix quality
+	Connected           bool
+	ReconnectAttempts   int64 // consecutive failed reconnects
+	LastReconnectError  string
+	Subscribers         int64            // connected clients of /stream, --binary-listen and --grpc-listen
+	DroppedUpdates      map[string]int64 // updates dropped per output as it was too slow
+	QueuedUpdates       map[string]int64 // updates queued per output
+	// SerialErrors are the error counters of the serial driver, nil if they are not available
+	SerialErrors *serialErrorCounts `json:",omitempty"`
+}
+
+// currentStatistics collects the current statistics
+func currentStatistics() statistics {
+	now := time.Now()
+	s := statistics{
+		Sentences:           sentences.Load(),
+		ReadErrors:          readErrors.Load(),
+		DroppedSentences:    droppedSentences.Load(),
+		QueuedSentences:     queuedSentences.Load(),
+		ParseErrors:         parseErrors.Load(),
+		RejectedCoordinates: rejectedCoordinates.Load(),
+		RejectedHDOP:        rejectedHDOP.Load(),
+		RejectedSatellites:  rejectedSatellites.Load(),
+		WebhooksPosted:      webhooksPosted.Load(),
+		WebhooksSuppressed:  webhooksSuppressed.Load(),
+		DegradedSentences:   degradedSentences.Load(),
+		Uptime:              now.Sub(started),
+		FixesPerSecond:      fixRate.perSecond(now),
+		Subscribers:         subscribers.Load(),
+		DroppedUpdates:      droppedUpdates(),
+		QueuedUpdates:       queuedUpdates(),
+	}
+	s.Connected, s.ReconnectAttempts, s.LastReconnectError = conn.state()
+	s.FixQualityAlarm, s.FixQualityDurations = fixQualityAlarm.state()
+	if c, ok := serialErrors(); ok {
+		s.SerialErrors = &c
+	}
+	if t := firstFix.Load(); t != 0 {
+		s.TTFF = time.Unix(0, t).Sub(started)
+	}
+	if t := lastFix.Load(); t != 0 {
+		s.FixAge = now.Sub(time.Unix(0, t))
+	}
+	if t := fixLostAt.Load(); t != 0 {
+		s.FixLostAt = time.Unix(0, t)
+	}
+	if t := fixAcquiredAt.Load(); t != 0 {
+		s.FixAcquiredAt = time.Unix(0, t)
+	}
+	return s
+}
+
+// HTTP Handler to send the statistics as JSON
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	js, err := json.Marshal(currentStatistics())
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
+
+// Health states of /healthz
+const (
+	healthOK           = "ok"
+	healthNoFix        = "no fix"
+	healthDisconnected = "disconnected"
+)
+
+// health is the JSON of the /healthz endpoint
+type health struct {
+	Status             string
+	ReconnectAttempts  int64
+	LastReconnectError string
+}
+
+// HTTP Handler to report the health. It distinguishes a connected GPS sensor without fix from a
+// GPS sensor that can't be opened at all, both respond with 503.
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	var h health
+	var connected bool
+	connected, h.ReconnectAttempts, h.LastReconnectError = conn.state()
+	d.m.Lock()
+	fix := d.fix
+	d.m.Unlock()
+
+	code := http.StatusOK
+	switch {
+	case !connected:
+		h.Status = healthDisconnected
+		code = http.StatusServiceUnavailable
+	case !fix:
+		h.Status = healthNoFix
+		code = http.StatusServiceUnavailable
+	default:
+		h.Status = healthOK
+	}
+
+	js, err := json.Marshal(h)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(js)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// hub distributes the GPS data to subscribers, e.g. of the /stream endpoint
+type hub struct {
+	m      *sync.Mutex
+	subs   map[chan []byte]bool
+	encode func(p data) ([]byte, error)
+	full   func(p data) ([]byte, error) // sent instead to subscribers that skipped an update, if set
+	reset  func()                       // makes the next update of 'encode' a full one, with 'full'
+	latest data                         // last published data, also without subscribers
+	seen   bool                         // true once 'latest' is set
+}
+
+// streams is the hub for all stream subscribers, fed whenever 'd' is stored
+var streams = hub{
+	m:      &sync.Mutex{},
+	subs:   map[chan []byte]bool{},
+	encode: encodeJSON,
+}
+
+// encodeJSON encodes 'p' as JSON with the current age
+func encodeJSON(p data) ([]byte, error) {
+	p.Age = time.Since(p.update)
+	return json.Marshal(inUnits(p))
+}
+
+// errTooManySubscribers is returned by subscribe if --max-subscribers are connected
+var errTooManySubscribers = errors.New("too many subscribers")
+
+// subscribe registers a new subscriber. It only holds the latest update, so a slow subscriber
+// skips updates instead of blocking the publisher. The subscribers of all hubs are limited to
+// --max-subscribers. With 'full' the subscriber starts with a full snapshot of the last published
+// data and the next update is a full one for all subscribers, so they share the same baseline.
+func (h *hub) subscribe() (chan []byte, error) {
+	n := subscribers.Add(1)
+	if *maxSubscribers > 0 && n > int64(*maxSubscribers) {
+		subscribers.Add(-1)
+		return nil, errTooManySubscribers
+	}
+	c := make(chan []byte, 1)
+	h.m.Lock()
+	defer h.m.Unlock()
+	if h.full != nil {
+		p := h.latest
+		if !h.seen {
+			p = snapshot()
+		}
+		js, err := h.full(p)
+		if err != nil {
+			subscribers.Add(-1)
+			return nil, err
+		}
+		c <- js
+		if h.reset != nil {
+			h.reset()
+		}
+	}
+	h.subs[c] = true
+	return c, nil
+}
+
+// unsubscribe removes the subscriber 'c'
+func (h *hub) unsubscribe(c chan []byte) {
+	h.m.Lock()
+	delete(h.subs, c)
+	h.m.Unlock()
+	subscribers.Add(-1)
+}
+
+// Publish sends the encoded 'p' to all subscribers
+func (h *hub) Publish(ctx context.Context, p data) error {
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.latest, h.seen = p, true
+	if len(h.subs) == 0 {
+		return nil
+	}
+
+	js, err := h.encode(p)
+	if err != nil || js == nil {
+		return err
+	}
+	var full []byte
+	for c := range h.subs {
+		// Replace an update the subscriber did not receive yet
+		skipped := false
+		select {
+		case <-c:
+			skipped = true
+		default:
+		}
+		if skipped && h.full != nil {
+			if full == nil {
+				full, err = h.full(p)
+				if err != nil {
+					return err
+				}
+			}
+			c <- full
+			continue
+		}
+		c <- js
+	}
+	return nil
+}
+
+// HTTP Handler to stream 'd' as server-sent events whenever it is updated. With ?delta=true only
+// the changed fields are sent after a full snapshot.
+func streamHandler(w http.ResponseWriter, r *http.Request) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		httpError(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	h := &streams
+	delta := r.URL.Query().Get("delta") == "true"
+	if delta {
+		h = &deltas
+	}
+	c, err := h.subscribe()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer h.unsubscribe(c)
+
+	// With ?delta=true the subscriber has a full snapshot queued, the deltas are relative to it
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	f.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case js := <-c:
+			fmt.Fprintf(w, "data: %s\n\n", js)
+			f.Flush()
+		}
+	}
+}
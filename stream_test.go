@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMaxSubscribers(t *testing.T) {
+	old := *maxSubscribers
+	*maxSubscribers = 2
+	defer func() { *maxSubscribers = old }()
+
+	// The limit applies to the subscribers of all hubs together
+	a, err := streams.subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := deltas.subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := streams.subscribe(); !errors.Is(err, errTooManySubscribers) {
+		t.Errorf("third subscribe returned %v, want %v", err, errTooManySubscribers)
+	}
+	if got := subscribers.Load(); got != 2 {
+		t.Errorf("%v subscribers, want 2", got)
+	}
+
+	// /stream rejects further clients with 503
+	w := httptest.NewRecorder()
+	streamHandler(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("/stream responded with %v, want %v", w.Code, http.StatusServiceUnavailable)
+	}
+
+	// A free slot can be taken again
+	streams.unsubscribe(a)
+	c, err := streams.subscribe()
+	if err != nil {
+		t.Errorf("subscribe after unsubscribe failed, %v", err)
+	}
+	streams.unsubscribe(c)
+	deltas.unsubscribe(b)
+	if got := subscribers.Load(); got != 0 {
+		t.Errorf("%v subscribers after unsubscribing all, want 0", got)
+	}
+}
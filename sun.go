@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+)
+
+const (
+	julianUnixEpoch = 2440587.5 // Julian date of the unix epoch
+	julianJ2000     = 2451545.0 // Julian date of 2000-01-01 12:00 UTC
+	sunAltitude     = -0.833    // altitude of the sun's center at sunrise and sunset in degrees, including refraction
+	earthObliquity  = 23.4397   // obliquity of the ecliptic in degrees
+)
+
+// sunTimes is the JSON of /sun. Sunrise and sunset are omitted during polar day and night.
+type sunTimes struct {
+	Date           string     // day of the times, YYYY-MM-DD in UTC
+	Sunrise        *time.Time `json:",omitempty"`
+	Sunset         *time.Time `json:",omitempty"`
+	SolarNoon      time.Time
+	SunriseLocal   *time.Time `json:",omitempty"`
+	SunsetLocal    *time.Time `json:",omitempty"`
+	SolarNoonLocal time.Time
+	PolarDay       bool // the sun does not set on this day
+	PolarNight     bool // the sun does not rise on this day
+}
+
+// julianTime converts the Julian date 'jd' to a time
+func julianTime(jd float64) time.Time {
+	return time.Unix(0, int64((jd-julianUnixEpoch)*86400*float64(time.Second))).UTC().Round(time.Second)
+}
+
+// sunTimesOn computes sunrise, sunset and solar noon on the UTC day of 'day' at the position
+// using the sunrise equation, which is accurate to about a minute
+func sunTimesOn(day time.Time, lat, lon float64) sunTimes {
+	rad := math.Pi / 180
+	day = day.UTC()
+	noon := time.Date(day.Year(), day.Month(), day.Day(), 12, 0, 0, 0, time.UTC)
+	n := math.Round(float64(noon.Unix())/86400 + julianUnixEpoch - julianJ2000 + 0.0008)
+
+	// Mean solar time, solar mean anomaly, equation of the center and ecliptic longitude
+	j := n - lon/360
+	m := math.Mod(357.5291+0.98560028*j, 360)
+	c := 1.9148*math.Sin(m*rad) + 0.02*math.Sin(2*m*rad) + 0.0003*math.Sin(3*m*rad)
+	λ := math.Mod(m+c+180+102.9372, 360)
+	transit := julianJ2000 + j + 0.0053*math.Sin(m*rad) - 0.0069*math.Sin(2*λ*rad)
+
+	// Declination of the sun and hour angle of sunrise and sunset
+	sinδ := math.Sin(λ*rad) * math.Sin(earthObliquity*rad)
+	cosδ := math.Cos(math.Asin(sinδ))
+	cosω := (math.Sin(sunAltitude*rad) - math.Sin(lat*rad)*sinδ) / (math.Cos(lat*rad) * cosδ)
+
+	s := sunTimes{Date: day.Format("2006-01-02"), SolarNoon: julianTime(transit)}
+	s.SolarNoonLocal = s.SolarNoon.In(location)
+	switch {
+	case cosω < -1:
+		s.PolarDay = true
+	case cosω > 1:
+		s.PolarNight = true
+	default:
+		ω := math.Acos(cosω) / rad
+		rise, set := julianTime(transit-ω/360), julianTime(transit+ω/360)
+		riseLocal, setLocal := rise.In(location), set.In(location)
+		s.Sunrise, s.Sunset = &rise, &set
+		s.SunriseLocal, s.SunsetLocal = &riseLocal, &setLocal
+	}
+	return s
+}
+
+// sunHandler responds with sunrise, sunset and solar noon of today at the current position
+func sunHandler(w http.ResponseWriter, r *http.Request) {
+	d.m.Lock()
+	fix, lat, lon, ts := d.fix, d.Latitude, d.Longitude, d.Timestamp
+	d.m.Unlock()
+	if !fix {
+		httpError(w, "no GPS fix", http.StatusServiceUnavailable)
+		return
+	}
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	js, err := json.Marshal(sunTimesOn(ts, lat, lon))
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// trackPoint is a single recorded fix
+type trackPoint struct {
+	Timestamp time.Time
+	Latitude  float64
+	Longitude float64
+	Altitude  *float64 `json:",omitempty"` // nil without a plausible altitude
+	Speed     float64
+}
+
+// track records the fixes of this session in a bounded buffer and sums up the travelled distance.
+// Fixes below --track-min-fix or --track-min-sats are rejected and only counted.
+type track struct {
+	m        *sync.Mutex
+	Points   []trackPoint
+	Distance float64 // in meters, in the distance unit of --units on /track
+	Rejected int64
+	recorded int64  // number of recorded points, including those dropped from Points
+	bounds   bounds // bounding box of all recorded points, updated with every point
+}
+
+// tr is the track of this session, recorded from 'd' whenever the position is stored
+var tr = track{
+	m: &sync.Mutex{},
+}
+
+// recordable returns true if the fix of 'p' meets the quality thresholds of the track
+func recordable(p data) bool {
+	return p.fix && fixTypeRank[p.FixType] >= fixTypeRank[*trackMinFix] && p.Satellites >= int64(*trackMinSats)
+}
+
+// Publish records the position of 'p' if it meets the quality thresholds
+func (t *track) Publish(ctx context.Context, p data) error {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if !recordable(p) {
+		t.Rejected++
+		return nil
+	}
+
+	if n := len(t.Points); n > 0 {
+		last := t.Points[n-1]
+		t.Distance += distance(last.Latitude, last.Longitude, p.Latitude, p.Longitude)
+	}
+	t.bounds.extend(p.Latitude, p.Longitude, t.recorded == 0)
+	t.recorded++
+	t.Points = append(t.Points, trackPoint{
+		Timestamp: p.Timestamp,
+		Latitude:  p.Latitude,
+		Longitude: p.Longitude,
+		Altitude:  p.Altitude,
+		Speed:     p.Speed,
+	})
+	if len(t.Points) > *trackSize {
+		t.Points = t.Points[len(t.Points)-*trackSize:]
+	}
+	return nil
+}
+
+// HTTP Handler to send the track as JSON
+func trackHandler(w http.ResponseWriter, r *http.Request) {
+	tr.m.Lock()
+	t := track{
+		Points:   make([]trackPoint, len(tr.Points)),
+		Distance: tr.Distance * units().distance,
+		Rejected: tr.Rejected,
+	}
+	for i, p := range tr.Points {
+		t.Points[i] = pointInUnits(p)
+	}
+	tr.m.Unlock()
+	js, err := json.Marshal(t)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
+
+// at returns the position at 'ts', linearly interpolated between the two nearest points, and false
+// if 'ts' is outside of the recorded points. Points are interpolated across the antimeridian.
+func (t *track) at(ts time.Time) (trackPoint, bool) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	i := sort.Search(len(t.Points), func(i int) bool { return !t.Points[i].Timestamp.Before(ts) })
+	switch {
+	case i == len(t.Points):
+		return trackPoint{}, false
+	case t.Points[i].Timestamp.Equal(ts):
+		return t.Points[i], true
+	case i == 0:
+		return trackPoint{}, false
+	}
+
+	a, b := t.Points[i-1], t.Points[i]
+	f := float64(ts.Sub(a.Timestamp)) / float64(b.Timestamp.Sub(a.Timestamp))
+	lerp := func(x, y float64) float64 { return x + (y-x)*f }
+	p := trackPoint{
+		Timestamp: ts,
+		Latitude:  lerp(a.Latitude, b.Latitude),
+		Longitude: normalizeLongitude(a.Longitude + normalizeLongitude(b.Longitude-a.Longitude)*f),
+		Speed:     lerp(a.Speed, b.Speed),
+	}
+	// The altitude is only interpolated between two plausible altitudes
+	if a.Altitude != nil && b.Altitude != nil {
+		alt := lerp(*a.Altitude, *b.Altitude)
+		p.Altitude = &alt
+	}
+	return p, true
+}
+
+// HTTP Handler to send the position at the time given by ?time=<RFC 3339> as JSON, responds with
+// 404 if the time is outside of the track
+func atHandler(w http.ResponseWriter, r *http.Request) {
+	ts, err := time.Parse(time.RFC3339, r.URL.Query().Get("time"))
+	if err != nil {
+		httpError(w, fmt.Sprintf("invalid time, %v", err), http.StatusBadRequest)
+		return
+	}
+	p, ok := tr.at(ts)
+	if !ok {
+		httpError(w, "time outside of the track", http.StatusNotFound)
+		return
+	}
+	js, err := json.Marshal(pointInUnits(p))
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
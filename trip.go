@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tripEnd is the start or the end of a trip
+type tripEnd struct {
+	Timestamp time.Time
+	Latitude  float64
+	Longitude float64
+}
+
+// trip sums up the fixes recorded to the track since the start, POST /trip/reset or a gap of
+// --track-gap or --track-gap-distance. Unlike the track it is not bounded by --track-size.
+type trip struct {
+	m           *sync.Mutex
+	points      int64
+	start, end  tripEnd
+	distance    float64  // in meters
+	maxSpeed    float64  // in km/h
+	maxAltitude *float64 // in meters, nil without a plausible altitude
+}
+
+// currentTrip is the trip of /trip
+var currentTrip = trip{
+	m: &sync.Mutex{},
+}
+
+// tripSummary is the JSON of /trip
+type tripSummary struct {
+	Points       int64
+	Start        *tripEnd `json:",omitempty"`
+	End          *tripEnd `json:",omitempty"`
+	Duration     time.Duration
+	Distance     float64
+	AverageSpeed float64
+	MaxSpeed     float64
+	MaxAltitude  *float64 `json:",omitempty"`
+}
+
+// gap returns true if the fix of 'p' is too far in time or distance from the end of the trip
+func (t *trip) gap(p data) bool {
+	if t.points == 0 {
+		return false
+	}
+	return (*trackGap > 0 && p.Timestamp.Sub(t.end.Timestamp) > *trackGap) ||
+		(*trackGapDistance > 0 && distance(t.end.Latitude, t.end.Longitude, p.Latitude, p.Longitude) > *trackGapDistance)
+}
+
+// Publish adds the fix of 'p' to the trip if it meets the quality thresholds of the track. After a
+// gap a new trip is started.
+func (t *trip) Publish(ctx context.Context, p data) error {
+	if !recordable(p) {
+		return nil
+	}
+	t.m.Lock()
+	defer t.m.Unlock()
+	if t.gap(p) {
+		event(eventTrip, "new trip after %.0fm in %v", t.distance, t.end.Timestamp.Sub(t.start.Timestamp))
+		t.reset()
+	}
+
+	end := tripEnd{Timestamp: p.Timestamp, Latitude: p.Latitude, Longitude: p.Longitude}
+	if t.points == 0 {
+		t.start = end
+	} else {
+		t.distance += distance(t.end.Latitude, t.end.Longitude, p.Latitude, p.Longitude)
+	}
+	t.end = end
+	t.points++
+	t.maxSpeed = max(t.maxSpeed, p.Speed)
+	if p.Altitude != nil && (t.maxAltitude == nil || *p.Altitude > *t.maxAltitude) {
+		t.maxAltitude = p.Altitude
+	}
+	return nil
+}
+
+// reset starts a new trip
+func (t *trip) reset() {
+	t.points, t.start, t.end = 0, tripEnd{}, tripEnd{}
+	t.distance, t.maxSpeed, t.maxAltitude = 0, 0, nil
+}
+
+// summary returns the summary of the trip in the units of --units
+func (t *trip) summary() tripSummary {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.summarize()
+}
+
+// restart starts a new trip and returns the summary of the finished one
+func (t *trip) restart() tripSummary {
+	t.m.Lock()
+	defer t.m.Unlock()
+	s := t.summarize()
+	t.reset()
+	return s
+}
+
+// summarize returns the summary of the trip while it is locked
+func (t *trip) summarize() tripSummary {
+	u := units()
+	s := tripSummary{
+		Points:      t.points,
+		Distance:    t.distance * u.distance,
+		MaxSpeed:    t.maxSpeed * u.speed,
+		MaxAltitude: scaled(t.maxAltitude, u.altitude),
+	}
+	if t.points == 0 {
+		return s
+	}
+	start, end := t.start, t.end
+	s.Start, s.End = &start, &end
+	s.Duration = end.Timestamp.Sub(start.Timestamp)
+	if h := s.Duration.Hours(); h > 0 {
+		s.AverageSpeed = t.distance / 1000 / h * u.speed
+	}
+	return s
+}
+
+// HTTP Handler to send the summary of the current trip as JSON
+func tripHandler(w http.ResponseWriter, r *http.Request) {
+	js, err := json.Marshal(currentTrip.summary())
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
+
+// HTTP Handler to start a new trip, it responds with the summary of the finished one
+func tripResetHandler(w http.ResponseWriter, r *http.Request) {
+	s := currentTrip.restart()
+	event(eventTrip, "new trip")
+	js, err := json.Marshal(s)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Unit systems of --units
+const (
+	unitsMetric   = "metric"
+	unitsImperial = "imperial"
+	unitsNautical = "nautical"
+)
+
+// Conversion factors of speeds from km/h
+const (
+	kmhToKnots = 1 / knotsToKmh
+	kmhToMs    = 1 / 3.6
+	kmhToMph   = 1 / 1.609344
+)
+
+// speedUnits are the units of /speed?unit= with their conversion factors from km/h
+var speedUnits = map[string]float64{
+	"kmh": 1,
+	"kn":  kmhToKnots,
+	"ms":  kmhToMs,
+	"mph": kmhToMph,
+}
+
+// unitSystem holds the conversion factors from the internal units km/h, meters and m/s
+type unitSystem struct {
+	speed         float64 // Speed and SpeedSmoothed, from km/h
+	altitude      float64 // Altitude, AltitudeRelative and the errors of GST, from meters
+	verticalSpeed float64 // VerticalSpeed, from m/s
+	distance      float64 // distances like the track length, from meters
+	speedUnit     string  // unit of /speed without ?unit=
+}
+
+// unitSystems are the unit systems of --units:
+//   - metric: km/h, meters, m/s and meters
+//   - imperial: mph, feet, ft/s and miles
+//   - nautical: knots, meters, m/s and nautical miles
+var unitSystems = map[string]unitSystem{
+	unitsMetric:   {speed: 1, altitude: 1, verticalSpeed: 1, distance: 1, speedUnit: "kmh"},
+	unitsImperial: {speed: kmhToMph, altitude: 1 / 0.3048, verticalSpeed: 1 / 0.3048, distance: 1 / 1609.344, speedUnit: "mph"},
+	unitsNautical: {speed: kmhToKnots, altitude: 1, verticalSpeed: 1, distance: 1 / 1852.0, speedUnit: "kn"},
+}
+
+// siValues are the speeds and altitudes in SI units, for clients that don't want --units
+type siValues struct {
+	Speed            float64  // m/s
+	SpeedSmoothed    float64  // m/s
+	Altitude         *float64 `json:",omitempty"` // meters
+	AltitudeRelative *float64 `json:",omitempty"` // meters
+	VerticalSpeed    float64  // m/s
+	LatitudeError    *float64 `json:",omitempty"` // meters
+	LongitudeError   *float64 `json:",omitempty"` // meters
+	AltitudeError    *float64 `json:",omitempty"` // meters
+}
+
+// units returns the unit system selected by --units
+func units() unitSystem {
+	return unitSystems[*outputUnits]
+}
+
+// inUnits converts the speeds and altitudes of 'p' to the units of --units. With a unit system
+// other than metric the SI values are added as SI.
+func inUnits(p data) data {
+	if *outputUnits == unitsMetric {
+		return p
+	}
+	si := siValues{
+		Speed:            p.Speed * kmhToMs,
+		SpeedSmoothed:    p.SpeedSmoothed * kmhToMs,
+		Altitude:         p.Altitude,
+		AltitudeRelative: p.AltitudeRelative,
+		VerticalSpeed:    p.VerticalSpeed,
+		LatitudeError:    p.LatitudeError,
+		LongitudeError:   p.LongitudeError,
+		AltitudeError:    p.AltitudeError,
+	}
+	u := units()
+	p.Speed *= u.speed
+	p.SpeedSmoothed *= u.speed
+	p.Altitude = scaled(p.Altitude, u.altitude)
+	p.VerticalSpeed *= u.verticalSpeed
+	p.AltitudeRelative = scaled(p.AltitudeRelative, u.altitude)
+	p.LatitudeError = scaled(p.LatitudeError, u.altitude)
+	p.LongitudeError = scaled(p.LongitudeError, u.altitude)
+	p.AltitudeError = scaled(p.AltitudeError, u.altitude)
+	p.Units = *outputUnits
+	p.SI = &si
+	return p
+}
+
+// scaled returns a copy of 'v' multiplied by 'factor', nil if 'v' is nil
+func scaled(v *float64, factor float64) *float64 {
+	if v == nil {
+		return nil
+	}
+	s := *v * factor
+	return &s
+}
+
+// pointInUnits converts the speed and altitude of the track point 't' to the units of --units
+func pointInUnits(t trackPoint) trackPoint {
+	u := units()
+	t.Speed *= u.speed
+	t.Altitude = scaled(t.Altitude, u.altitude)
+	return t
+}
+
+// HTTP Handler to send the speed over ground as plain text in the unit of ?unit=kmh|kn|ms|mph,
+// the speed unit of --units by default
+func speedHandler(w http.ResponseWriter, r *http.Request) {
+	unit := r.URL.Query().Get("unit")
+	if unit == "" {
+		unit = units().speedUnit
+	}
+	factor, ok := speedUnits[unit]
+	if !ok {
+		httpError(w, fmt.Sprintf("invalid unit %q, must be kmh, kn, ms or mph", unit), http.StatusBadRequest)
+		return
+	}
+	// The speed is not restored from the state file
+	plainHandler(func() []float64 { return []float64{d.Speed * factor} }, false)(w, r)
+}
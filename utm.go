@@ -0,0 +1,81 @@
+package main
+
+import "math"
+
+// Parameters of the WGS84 ellipsoid and the UTM projection
+const (
+	wgs84A        = 6378137.0         // semi-major axis in meters
+	wgs84F        = 1 / 298.257223563 // flattening
+	utmScale      = 0.9996            // scale factor on the central meridian
+	utmFalseEast  = 500000.0          // false easting in meters
+	utmFalseNorth = 10000000.0        // false northing in meters on the southern hemisphere
+)
+
+// utmBands are the latitude bands of 8 degrees from 80°S, X spans 12 degrees up to 84°N
+const utmBands = "CDEFGHJKLMNPQRSTUVWX"
+
+// utmCoordinate is a position in UTM coordinates
+type utmCoordinate struct {
+	Zone       int
+	Band       string
+	Hemisphere string // "N" or "S"
+	Easting    float64
+	Northing   float64
+}
+
+// utmZone returns the UTM zone of the position, including the exceptions of southwest Norway and
+// Svalbard
+func utmZone(lat, lon float64) int {
+	switch {
+	case lat >= 56 && lat < 64 && lon >= 3 && lon < 12:
+		return 32
+	case lat >= 72 && lon >= 0 && lon < 9:
+		// Svalbard only has the odd zones 31, 33, 35 and 37
+		return 31
+	case lat >= 72 && lon >= 9 && lon < 21:
+		return 33
+	case lat >= 72 && lon >= 21 && lon < 33:
+		return 35
+	case lat >= 72 && lon >= 33 && lon < 42:
+		return 37
+	}
+	return int(math.Floor((normalizeLongitude(lon)+180)/6))%60 + 1
+}
+
+// toUTM converts the WGS84 position to UTM using the series expansion of Snyder, which is accurate
+// to a few millimeters within a zone. It returns nil outside of the UTM latitudes of 80°S to 84°N.
+func toUTM(lat, lon float64) *utmCoordinate {
+	if lat < -80 || lat > 84 {
+		return nil
+	}
+	zone := utmZone(lat, lon)
+	lon0 := float64(zone-1)*6 - 180 + 3
+
+	e2 := wgs84F * (2 - wgs84F)
+	e4, e6 := e2*e2, e2*e2*e2
+	ep2 := e2 / (1 - e2)
+	φ := lat * math.Pi / 180
+	Δλ := normalizeLongitude(lon-lon0) * math.Pi / 180
+
+	n := wgs84A / math.Sqrt(1-e2*math.Sin(φ)*math.Sin(φ))
+	t := math.Tan(φ) * math.Tan(φ)
+	c := ep2 * math.Cos(φ) * math.Cos(φ)
+	a := math.Cos(φ) * Δλ
+	m := wgs84A * ((1-e2/4-3*e4/64-5*e6/256)*φ -
+		(3*e2/8+3*e4/32+45*e6/1024)*math.Sin(2*φ) +
+		(15*e4/256+45*e6/1024)*math.Sin(4*φ) -
+		(35*e6/3072)*math.Sin(6*φ))
+
+	u := &utmCoordinate{Zone: zone, Band: string(utmBands[min(int((lat+80)/8), len(utmBands)-1)]), Hemisphere: "N"}
+	u.Easting = utmFalseEast + utmScale*n*(a+(1-t+c)*math.Pow(a, 3)/6+
+		(5-18*t+t*t+72*c-58*ep2)*math.Pow(a, 5)/120)
+	u.Northing = utmScale * (m + n*math.Tan(φ)*(a*a/2+(5-t+9*c+4*c*c)*math.Pow(a, 4)/24+
+		(61-58*t+t*t+600*c-330*ep2)*math.Pow(a, 6)/720))
+	if lat < 0 {
+		u.Hemisphere = "S"
+		u.Northing += utmFalseNorth
+	}
+	u.Easting = math.Round(u.Easting*1000) / 1000
+	u.Northing = math.Round(u.Northing*1000) / 1000
+	return u
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Triggers of the webhook selected by --webhook-on
+const (
+	webhookPosition = "position" // every --webhook-interval, on geofence transitions and alarms
+	webhookGeofence = "geofence" // only on geofence transitions and alarms
+)
+
+// webhookFixQuality is the trigger of the payloads posted when the fix quality alarm is raised or
+// cleared
+const webhookFixQuality = "fix-quality"
+
+const (
+	webhookVersion = 2                // version of the webhook payload
+	webhookTimeout = 10 * time.Second // Timeout for a webhook request
+)
+
+// webhookPayload is the JSON posted to --webhook
+type webhookPayload struct {
+	Version   int
+	Trigger   string // "position", "geofence" or "fix-quality"
+	Timestamp time.Time
+	Latitude  float64
+	Longitude float64
+	Altitude  *float64 `json:",omitempty"` // omitted without a plausible altitude
+	Speed     float64
+	Course    float64
+	Geofences transitions
+	// Added with version 2
+	FixQuality      string
+	FixQualityAlarm bool
+}
+
+// webhook posts the position to --webhook, with the geofences of --geofence entered and exited
+type webhook struct {
+	fences geofences
+	alarm  bool      // FixQualityAlarm of the last update
+	sent   time.Time // time of the last payload
+	due    time.Time // time the next position payload is due, after a sent or suppressed one
+	cell   string    // geohash of the last payload with --webhook-geohash-precision
+	client *http.Client
+}
+
+// hooks is the webhook of --webhook, also tracking the geofences if unset
+var hooks = webhook{
+	client: &http.Client{Timeout: webhookTimeout},
+}
+
+// Publish checks the position of 'p' against the geofences and posts it if the webhook is due.
+// Failed requests are not retried.
+func (h *webhook) Publish(ctx context.Context, p data) error {
+	t := h.fences.update(p)
+	alarmChanged := p.FixQualityAlarm != h.alarm
+	h.alarm = p.FixQualityAlarm
+	if *webhookURL == "" {
+		return nil
+	}
+
+	// The alarm is also posted without a fix, as losing the fix often raises it
+	var trigger string
+	switch {
+	case t.changed():
+		trigger = webhookGeofence
+	case alarmChanged:
+		trigger = webhookFixQuality
+	case !p.fix:
+		return nil
+	case *webhookOn == webhookPosition && !time.Now().Before(h.due):
+		trigger = webhookPosition
+	default:
+		return nil
+	}
+	// An asset that dwells in one geohash cell is only posted every --webhook-max-interval
+	var cell string
+	if *webhookGeohashPrecision > 0 {
+		cell = geohash(p.Latitude, p.Longitude, *webhookGeohashPrecision)
+		if trigger == webhookPosition && cell == h.cell && time.Since(h.sent) < *webhookMaxInterval {
+			webhooksSuppressed.Add(1)
+			h.due = time.Now().Add(*webhookInterval)
+			return nil
+		}
+	}
+	h.sent = time.Now()
+	h.due = h.sent.Add(*webhookInterval)
+	h.cell = cell
+
+	js, err := json.Marshal(webhookPayload{
+		Version:         webhookVersion,
+		Trigger:         trigger,
+		Timestamp:       p.Timestamp,
+		Latitude:        p.Latitude,
+		Longitude:       p.Longitude,
+		Altitude:        p.Altitude,
+		Speed:           p.Speed,
+		Course:          p.Course,
+		Geofences:       t,
+		FixQuality:      p.FixQuality,
+		FixQualityAlarm: p.FixQualityAlarm,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *webhookURL, bytes.NewReader(js))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded with %v", resp.Status)
+	}
+	webhooksPosted.Add(1)
+	return nil
+}
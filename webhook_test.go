@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testWebhook returns a webhook posting to a test server and the number of received payloads. The
+// flags are restored at the end of the test.
+func testWebhook(t *testing.T, interval time.Duration, precision int) (*webhook, *atomic.Int64) {
+	posted := &atomic.Int64{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted.Add(1)
+	}))
+	t.Cleanup(server.Close)
+	oldURL, oldOn, oldInterval, oldPrecision := *webhookURL, *webhookOn, *webhookInterval, *webhookGeohashPrecision
+	t.Cleanup(func() {
+		*webhookURL, *webhookOn, *webhookInterval, *webhookGeohashPrecision = oldURL, oldOn, oldInterval, oldPrecision
+	})
+	*webhookURL, *webhookOn, *webhookInterval, *webhookGeohashPrecision = server.URL, webhookPosition, interval, precision
+	return &webhook{client: server.Client()}, posted
+}
+
+func TestWebhookSuppressedEveryInterval(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	h, posted := testWebhook(t, interval, 5)
+	oldSuppressed := webhooksSuppressed.Load()
+	p := data{fix: true, Latitude: 48.1173, Longitude: 11.5167}
+	ctx := context.Background()
+
+	// Only the first fix is posted, the others stay in its cell
+	end := time.Now().Add(5*interval + interval/2)
+	for time.Now().Before(end) {
+		err := h.Publish(ctx, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := posted.Load(); got != 1 {
+		t.Errorf("posted %v payloads, want 1", got)
+	}
+	// One position payload is suppressed per interval, not every fix after the first interval
+	if got := webhooksSuppressed.Load() - oldSuppressed; got < 4 || got > 5 {
+		t.Errorf("suppressed %v payloads, want about 5, one per interval", got)
+	}
+}
+
+func TestWebhookAlarmWithoutFix(t *testing.T) {
+	h, posted := testWebhook(t, time.Hour, 0)
+	ctx := context.Background()
+	tests := []struct {
+		p    data
+		want int64
+	}{
+		{data{fix: true}, 1},
+		// The fix is lost and the alarm raised at once
+		{data{FixQualityAlarm: true}, 2},
+		{data{FixQualityAlarm: true}, 2},
+		{data{fix: true}, 3},
+	}
+	for i, tt := range tests {
+		err := h.Publish(ctx, tt.p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := posted.Load(); got != tt.want {
+			t.Errorf("%v: posted %v payloads, want %v", i, got, tt.want)
+		}
+	}
+}